@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"e_commerce/middleware"
+	"e_commerce/models"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// redacted fields are recorded as changed without their values.
+var redacted = map[string]bool{"Password": true, "TOTPSecret": true, "TokenHash": true, "CodeHash": true}
+
+// personal lists the fields that identify a person, by the type of the audited value. They are masked like
+// redacted fields so that erasing a user leaves no personal data in the append-only log.
+var personal = map[string]map[string]bool{
+	"User":           {"Name": true, "Surname": true, "Email": true},
+	"UserSummary":    {"Name": true, "Surname": true, "Email": true},
+	"UserIdentity":   {"Email": true},
+	"Address":        {"FullName": true, "Phone": true, "Line1": true, "Line2": true, "PostalCode": true},
+	"Order":          {"ShippingAddress": true, "BillingAddress": true},
+	"ShopInvitation": {"Email": true},
+}
+
+// Entry describes one change to an entity. Before is nil for creations and After is nil for deletions.
+// ActorID is only set for requests that identify the user by a signed link instead of a session.
+type Entry struct {
+	ActorID    uint
+	Action     string
+	EntityType string
+	EntityID   uint
+	Before     interface{}
+	After      interface{}
+	Reason     string
+}
+
+// Change is the value of a field before and after a change.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Record appends an entry to the audit log with the user and request ID of the request. Call it in the
+// transaction of the change so both are committed together. r may be nil for changes made by the system.
+func Record(db *gorm.DB, r *http.Request, e Entry) error {
+	changes, err := json.Marshal(Diff(e.Before, e.After))
+	if err != nil {
+		return err
+	}
+
+	log := models.AuditLog{
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Changes:    changes,
+		Reason:     e.Reason,
+		CreatedAt:  time.Now(),
+	}
+	if r != nil {
+		if claims, ok := r.Context().Value("user").(*models.Claims); ok && log.ActorID == 0 {
+			log.ActorID = claims.UserID
+		}
+		log.RequestID = r.Header.Get(middleware.RequestIDHeader)
+	}
+
+	return db.Create(&log).Error
+}
+
+// Diff returns the fields whose JSON values differ between before and after. Either may be nil.
+// Credentials and personal fields are listed with masked values.
+func Diff(before, after interface{}) map[string]Change {
+	b, a := fields(before), fields(after)
+	masked := personal[typeName(after)]
+	if after == nil {
+		masked = personal[typeName(before)]
+	}
+
+	diff := make(map[string]Change)
+	for name, value := range a {
+		if old, ok := b[name]; !ok || !reflect.DeepEqual(old, value) {
+			diff[name] = Change{Before: old, After: value}
+		}
+	}
+	for name, old := range b {
+		if _, ok := a[name]; !ok {
+			diff[name] = Change{Before: old}
+		}
+	}
+
+	delete(diff, "UpdatedAt")
+	for name, change := range diff {
+		if redacted[name] || masked[name] {
+			diff[name] = Change{Before: mask(change.Before), After: mask(change.After)}
+		}
+	}
+	return diff
+}
+
+// fields decodes the JSON form of v into a map. Values that are not JSON objects yield no fields.
+func fields(v interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	if v == nil {
+		return m
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+	json.Unmarshal(raw, &m)
+	return m
+}
+
+// typeName returns the name of the type of v, looking through pointers.
+func typeName(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func mask(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return "[redacted]"
+}
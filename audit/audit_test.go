@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"e_commerce/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := models.User{ID: 7, Name: "Ada", Surname: "Lovelace", Email: "ada@example.com", Password: "hash-1", Role: "customer", CreatedAt: created}
+
+	changed := func(change func(*models.User)) models.User {
+		u := user
+		change(&u)
+		return u
+	}
+
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]Change
+	}{
+		{"no change", user, user, map[string]Change{}},
+		{"plain field", user, changed(func(u *models.User) { u.Role = "seller" }),
+			map[string]Change{"Role": {Before: "customer", After: "seller"}}},
+		{"updated at is ignored", user, changed(func(u *models.User) { u.UpdatedAt = created }), map[string]Change{}},
+		{"credential", user, changed(func(u *models.User) { u.Password = "hash-2" }),
+			map[string]Change{"Password": {Before: "[redacted]", After: "[redacted]"}}},
+		{"personal fields of a user", user, changed(func(u *models.User) { u.Email = "ada@example.org"; u.Surname = "King" }),
+			map[string]Change{"Email": {Before: "[redacted]", After: "[redacted]"}, "Surname": {Before: "[redacted]", After: "[redacted]"}}},
+		{"pointer to a user", &user, changed(func(u *models.User) { u.Name = "Augusta" }),
+			map[string]Change{"Name": {Before: "[redacted]", After: "[redacted]"}}},
+		{"name of another entity is kept", models.Product{Name: "Lamp"}, models.Product{Name: "Desk lamp"},
+			map[string]Change{"Name": {Before: "Lamp", After: "Desk lamp"}}},
+		{"address lines", nil, models.Address{Title: "Home", FullName: "Ada Lovelace", Line1: "1 Main St", City: "London"},
+			map[string]Change{
+				"Title": {After: "Home"}, "FullName": {After: "[redacted]"}, "Line1": {After: "[redacted]"}, "City": {After: "London"},
+			}},
+		{"deletion", models.ShopInvitation{Email: "staff@example.com", Role: "viewer"}, nil,
+			map[string]Change{"Email": {Before: "[redacted]"}, "Role": {Before: "viewer"}}},
+	}
+
+	for _, tt := range tests {
+		got := Diff(tt.before, tt.after)
+		for name, change := range tt.want {
+			if !reflect.DeepEqual(got[name], change) {
+				t.Errorf("%s: %s = %#v, want %#v", tt.name, name, got[name], change)
+			}
+		}
+		if len(tt.want) == 0 && len(got) != 0 {
+			t.Errorf("%s: Diff = %#v, want no changes", tt.name, got)
+		}
+	}
+}
+
+func TestDiffNeverRecordsSecrets(t *testing.T) {
+	secrets := []string{"hash-1", "totp-secret", "ada@example.com", "Lovelace"}
+	before := models.User{Name: "Ada", Surname: "Lovelace", Email: "ada@example.com", Password: "hash-1", TOTPSecret: "totp-secret"}
+
+	for name, change := range Diff(before, nil) {
+		for _, secret := range secrets {
+			if change.Before == secret || change.After == secret {
+				t.Errorf("%s holds %q", name, secret)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Command mockoidc runs a local OpenID Connect issuer to try the social login without a real provider.
+//
+// Start it with `go run ./cmd/mockoidc` and configure the API with
+// OIDC_PROVIDERS=mock, OIDC_MOCK_ISSUER=http://localhost:9000 and OIDC_MOCK_CLIENT_ID=e-commerce.
+// Open /auth/mock/login?login_hint=someone@example.com to sign in as another user.
+package main
+
+import (
+	"e_commerce/oidc"
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL")
+	clientID := flag.String("client-id", "e-commerce", "accepted client id")
+	email := flag.String("email", "mock.user@example.com", "email of the signed in user")
+	name := flag.String("name", "Mock User", "name of the signed in user")
+	flag.Parse()
+
+	m, err := oidc.NewMockIssuer(*issuer, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m.Email = *email
+	m.Name = *name
+
+	log.Printf("Mock OIDC issuer %s listening on %s", m.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, m))
+}
@@ -0,0 +1,399 @@
+// Package config loads the application settings once at startup.
+//
+// Settings start from their defaults, are overridden by an optional YAML file and then by environment
+// variables. A .env file in the working directory is loaded first if it exists; variables already set in
+// the environment take precedence over it. The YAML file is given with the -config flag or CONFIG_FILE.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server   Server         `yaml:"server"`
+	Database Database       `yaml:"database"`
+	Auth     Auth           `yaml:"auth"`
+	Login    Login          `yaml:"login"`
+	Features Features       `yaml:"features"`
+	App      App            `yaml:"app"`
+	Mail     Mail           `yaml:"mail"`
+	Commerce Commerce       `yaml:"commerce"`
+	Jobs     Jobs           `yaml:"jobs"`
+	OIDC     []OIDCProvider `yaml:"oidc"`
+}
+
+type Server struct {
+	Addr              string        `yaml:"addr" env:"SERVER_ADDR"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"` // Kapanışta süren isteklerin beklendiği en uzun süre
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES"`
+	TrustProxy        bool          `yaml:"trust_proxy" env:"TRUST_PROXY"`     // İstemci adresi X-Forwarded-For başlığından alınır
+	ProxyHops         int           `yaml:"proxy_hops" env:"PROXY_HOPS"`       // Sunucunun önündeki güvenilen vekil sunucu sayısı
+	TLSCertFile       string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE"` // Sertifika ve anahtar verilirse HTTPS sunulur
+	TLSKeyFile        string        `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSReloadInterval time.Duration `yaml:"tls_reload_interval" env:"TLS_RELOAD_INTERVAL"` // Sertifika dosyalarının değişikliğe bakılma sıklığı, 0 yalnızca SIGHUP
+}
+
+type Database struct {
+	DSN             string        `yaml:"dsn" env:"DSL"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"` // 0 sınırsız
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"` // 0 sınırsız
+}
+
+type Auth struct {
+	JWTKey               string        `yaml:"jwt_key" env:"JWT_KEY"`
+	TOTPEncryptionKey    string        `yaml:"totp_encryption_key" env:"TOTP_ENCRYPTION_KEY"` // Base64, 32 bayt; 2FA anahtarlarını şifreler
+	SessionTTL           time.Duration `yaml:"session_ttl" env:"SESSION_TTL"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl" env:"PASSWORD_RESET_TTL"`
+	PasswordResetLimit   int           `yaml:"password_reset_limit" env:"PASSWORD_RESET_LIMIT"`       // PasswordResetWindow içinde e-posta başına istek sayısı
+	PasswordResetIPLimit int           `yaml:"password_reset_ip_limit" env:"PASSWORD_RESET_IP_LIMIT"` // PasswordResetWindow içinde IP başına istek sayısı
+	PasswordResetWindow  time.Duration `yaml:"password_reset_window" env:"PASSWORD_RESET_WINDOW"`
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl" env:"EMAIL_VERIFICATION_TTL"`
+	DeletionGraceDays    int           `yaml:"deletion_grace_days" env:"ACCOUNT_DELETION_GRACE_DAYS"` // Kapatılan hesabın silinmeden önce yeniden açılabileceği gün sayısı
+}
+
+type Login struct {
+	LockoutThreshold int           `yaml:"lockout_threshold" env:"LOGIN_LOCKOUT_THRESHOLD"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION"`
+	IPFreeAttempts   int           `yaml:"ip_free_attempts" env:"LOGIN_IP_FREE_ATTEMPTS"`
+	IPWindow         time.Duration `yaml:"ip_window" env:"LOGIN_IP_WINDOW"`
+}
+
+type Features struct {
+	RequireEmailVerification bool     `yaml:"require_email_verification" env:"REQUIRE_EMAIL_VERIFICATION"`
+	MFARequiredRoles         []string `yaml:"mfa_required_roles" env:"MFA_REQUIRED_ROLES"` // Ortam değişkeninde virgülle ayrılmış
+}
+
+type App struct {
+	Name string `yaml:"name" env:"APP_NAME"`
+	URL  string `yaml:"url" env:"APP_URL"` // E-postalardaki bağlantıların kök adresi
+
+	// ResetPasswordPath is the frontend page the reset link opens. It reads the token query parameter and
+	// posts it with the new password to /users/password/reset.
+	ResetPasswordPath string `yaml:"reset_password_path" env:"APP_RESET_PASSWORD_PATH"`
+
+	// AcceptInvitationPath is the frontend page the shop invitation link opens. It reads the token query
+	// parameter and posts it to /shop/invitations/accept for the signed-in user.
+	AcceptInvitationPath string `yaml:"accept_invitation_path" env:"APP_ACCEPT_INVITATION_PATH"`
+}
+
+type Mail struct {
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"` // Boşsa bildirimler log'a yazılır
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+}
+
+type Commerce struct {
+	VATRate                float64 `yaml:"vat_rate" env:"VAT_RATE"`                                 // Yüzde, fiyatlara dahil
+	PlatformCommissionRate float64 `yaml:"platform_commission_rate" env:"PLATFORM_COMMISSION_RATE"` // Yüzde, komisyon kuralı yoksa
+	ShippingSLAHours       int     `yaml:"shipping_sla_hours" env:"SHIPPING_SLA_HOURS"`
+}
+
+type Jobs struct {
+	PayoutInterval       time.Duration `yaml:"payout_interval" env:"PAYOUT_INTERVAL"`
+	PayoutMinAmount      float64       `yaml:"payout_min_amount" env:"PAYOUT_MIN_AMOUNT"`
+	NotificationInterval time.Duration `yaml:"notification_interval" env:"NOTIFICATION_INTERVAL"`
+	AccountPurgeInterval time.Duration `yaml:"account_purge_interval" env:"ACCOUNT_PURGE_INTERVAL"`
+}
+
+// OIDCProvider is an external identity provider. In the environment the providers are listed in
+// OIDC_PROVIDERS and each provider NAME is configured with OIDC_NAME_KIND, OIDC_NAME_CLIENT_ID,
+// OIDC_NAME_CLIENT_SECRET and OIDC_NAME_ISSUER.
+type OIDCProvider struct {
+	Name         string `yaml:"name"`
+	Kind         string `yaml:"kind"` // oidc, github; boşsa sağlayıcı adına göre seçilir
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	Issuer       string `yaml:"issuer"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			ProxyHops:         1,
+			TLSReloadInterval: time.Minute,
+		},
+		Database: Database{
+			MaxIdleConns:    2,
+			ConnMaxLifetime: time.Hour,
+		},
+		Auth: Auth{
+			SessionTTL:           168 * time.Hour,
+			PasswordResetTTL:     time.Hour,
+			PasswordResetLimit:   3,
+			PasswordResetIPLimit: 20,
+			PasswordResetWindow:  time.Hour,
+			EmailVerificationTTL: 24 * time.Hour,
+			DeletionGraceDays:    30,
+		},
+		Login: Login{
+			LockoutThreshold: 10,
+			LockoutDuration:  time.Hour,
+			IPFreeAttempts:   20,
+			IPWindow:         15 * time.Minute,
+		},
+		Features: Features{
+			RequireEmailVerification: true,
+		},
+		App: App{
+			Name:                 "E-Commerce",
+			URL:                  "http://localhost:8080",
+			ResetPasswordPath:    "/reset-password",
+			AcceptInvitationPath: "/shop-invitations/accept",
+		},
+		Mail: Mail{
+			SMTPPort: 25,
+		},
+		Commerce: Commerce{
+			PlatformCommissionRate: 10,
+			ShippingSLAHours:       48,
+		},
+		Jobs: Jobs{
+			PayoutInterval:       24 * time.Hour,
+			NotificationInterval: 10 * time.Second,
+			AccountPurgeInterval: time.Hour,
+		},
+	}
+}
+
+// Load reads the configuration and validates it. path is the YAML file; when empty CONFIG_FILE is used,
+// and without either only the defaults and the environment apply.
+func Load(path string) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("config: .env: %w", err)
+	}
+
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+	applyOIDCEnv(&cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server address (SERVER_ADDR) is required")
+	check(c.Server.ReadTimeout >= 0 && c.Server.ReadHeaderTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0,
+		"server timeouts cannot be negative")
+	check(c.Server.ShutdownTimeout > 0, "shutdown timeout (SERVER_SHUTDOWN_TIMEOUT) must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "max header size (SERVER_MAX_HEADER_BYTES) must be positive")
+	check(c.Server.ProxyHops > 0, "proxy hops (PROXY_HOPS) must be positive")
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "TLS needs both a certificate (TLS_CERT_FILE) and a key (TLS_KEY_FILE)")
+	check(c.Server.TLSReloadInterval >= 0, "TLS reload interval (TLS_RELOAD_INTERVAL) cannot be negative")
+
+	check(c.Database.DSN != "", "database DSN (DSL) is required")
+	check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0 && c.Database.ConnMaxLifetime >= 0,
+		"database pool settings cannot be negative")
+
+	check(c.Auth.JWTKey != "", "JWT key (JWT_KEY) is required")
+	check(c.Auth.JWTKey == "" || len(c.Auth.JWTKey) >= minKeyLength, "JWT key (JWT_KEY) must be at least %d bytes", minKeyLength)
+	check(!placeholderKey(c.Auth.JWTKey), "JWT key (JWT_KEY) is a placeholder, generate a random key")
+	totpKey, err := base64.StdEncoding.DecodeString(c.Auth.TOTPEncryptionKey)
+	check(err == nil && len(totpKey) == 32, "TOTP encryption key (TOTP_ENCRYPTION_KEY) must be 32 bytes in base64")
+	check(!placeholderKey(c.Auth.TOTPEncryptionKey) && !placeholderKey(string(totpKey)),
+		"TOTP encryption key (TOTP_ENCRYPTION_KEY) is a placeholder, generate a random key")
+	check(c.Auth.SessionTTL > 0, "session TTL (SESSION_TTL) must be positive")
+	check(c.Auth.PasswordResetTTL > 0, "password reset TTL (PASSWORD_RESET_TTL) must be positive")
+	check(c.Auth.PasswordResetLimit > 0 && c.Auth.PasswordResetIPLimit > 0,
+		"password reset limits (PASSWORD_RESET_LIMIT, PASSWORD_RESET_IP_LIMIT) must be positive")
+	check(c.Auth.PasswordResetWindow > 0, "password reset window (PASSWORD_RESET_WINDOW) must be positive")
+	check(c.Auth.EmailVerificationTTL > 0, "email verification TTL (EMAIL_VERIFICATION_TTL) must be positive")
+	check(c.Auth.DeletionGraceDays >= 0, "account deletion grace (ACCOUNT_DELETION_GRACE_DAYS) cannot be negative")
+
+	check(c.Login.LockoutThreshold > 0, "login lockout threshold (LOGIN_LOCKOUT_THRESHOLD) must be positive")
+	check(c.Login.LockoutDuration > 0, "login lockout duration (LOGIN_LOCKOUT_DURATION) must be positive")
+	check(c.Login.IPFreeAttempts > 0, "free login attempts per IP (LOGIN_IP_FREE_ATTEMPTS) must be positive")
+	check(c.Login.IPWindow > 0, "login IP window (LOGIN_IP_WINDOW) must be positive")
+
+	u, err := url.Parse(c.App.URL)
+	check(err == nil && u.Scheme != "" && u.Host != "", "app URL (APP_URL) must be an absolute URL")
+	check(strings.HasPrefix(c.App.ResetPasswordPath, "/"), "reset password path (APP_RESET_PASSWORD_PATH) must start with /")
+	check(strings.HasPrefix(c.App.AcceptInvitationPath, "/"), "accept invitation path (APP_ACCEPT_INVITATION_PATH) must start with /")
+
+	if c.Mail.SMTPHost != "" {
+		check(c.Mail.SMTPPort > 0 && c.Mail.SMTPPort < 65536, "SMTP port (SMTP_PORT) is invalid")
+		check(c.Mail.From != "", "sender address (MAIL_FROM) is required with SMTP")
+	}
+
+	check(c.Commerce.VATRate >= 0 && c.Commerce.VATRate <= 100, "VAT rate (VAT_RATE) must be between 0 and 100")
+	check(c.Commerce.PlatformCommissionRate >= 0 && c.Commerce.PlatformCommissionRate <= 100,
+		"platform commission rate (PLATFORM_COMMISSION_RATE) must be between 0 and 100")
+	check(c.Commerce.ShippingSLAHours > 0, "shipping SLA (SHIPPING_SLA_HOURS) must be positive")
+
+	check(c.Jobs.PayoutInterval > 0, "payout interval (PAYOUT_INTERVAL) must be positive")
+	check(c.Jobs.PayoutMinAmount >= 0, "payout minimum amount (PAYOUT_MIN_AMOUNT) cannot be negative")
+	check(c.Jobs.NotificationInterval > 0, "notification interval (NOTIFICATION_INTERVAL) must be positive")
+	check(c.Jobs.AccountPurgeInterval > 0, "account purge interval (ACCOUNT_PURGE_INTERVAL) must be positive")
+
+	seen := make(map[string]bool, len(c.OIDC))
+	for _, p := range c.OIDC {
+		check(p.Name != "", "OIDC provider name is required")
+		check(!seen[p.Name], "OIDC provider %s is configured twice", p.Name)
+		seen[p.Name] = true
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// minKeyLength is the shortest signing key accepted, in bytes.
+const minKeyLength = 32
+
+// placeholderKey reports whether a key is built from the change-me placeholder, like the keys that were once
+// shipped in the example configuration.
+func placeholderKey(key string) bool {
+	return strings.Contains(strings.ToLower(key), "change-me")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides the fields tagged with env whose variable is set. Empty variables are ignored,
+// except for lists where an empty value clears the list.
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok || (raw == "" && field.Type.Kind() != reflect.Slice) {
+			continue
+		}
+		if err := setValue(value, strings.TrimSpace(raw)); err != nil {
+			return fmt.Errorf("config: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// applyOIDCEnv replaces the providers of the YAML file when OIDC_PROVIDERS is set.
+func applyOIDCEnv(cfg *Config) {
+	list, ok := os.LookupEnv("OIDC_PROVIDERS")
+	if !ok {
+		return
+	}
+
+	cfg.OIDC = nil
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg.OIDC = append(cfg.OIDC, OIDCProvider{
+			Name:         name,
+			Kind:         os.Getenv(prefix + "KIND"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+		})
+	}
+}
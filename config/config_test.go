@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testJWTKey  = "0123456789abcdef0123456789abcdef"
+	testTOTPKey = "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="
+)
+
+// setRequired sets the settings that have no default, so Load only fails on what a test changes.
+func setRequired(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DSL", "user:pass@tcp(localhost:3306)/shop")
+	t.Setenv("JWT_KEY", testJWTKey)
+	t.Setenv("TOTP_ENCRYPTION_KEY", testTOTPKey)
+}
+
+func writeYAML(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		get  func(*Config) interface{}
+		want interface{}
+	}{
+		{"default", "", map[string]string{"SERVER_ADDR": ""},
+			func(c *Config) interface{} { return c.Server.Addr }, ":8080"},
+		{"yaml over default", "server:\n  addr: \":9000\"\n", map[string]string{"SERVER_ADDR": ""},
+			func(c *Config) interface{} { return c.Server.Addr }, ":9000"},
+		{"env over yaml", "server:\n  addr: \":9000\"\n", map[string]string{"SERVER_ADDR": ":9100"},
+			func(c *Config) interface{} { return c.Server.Addr }, ":9100"},
+		{"env over default", "", map[string]string{"LOGIN_LOCKOUT_THRESHOLD": "5"},
+			func(c *Config) interface{} { return c.Login.LockoutThreshold }, 5},
+		{"yaml keeps other defaults", "login:\n  lockout_threshold: 7\n", map[string]string{"LOGIN_LOCKOUT_DURATION": ""},
+			func(c *Config) interface{} { return c.Login.LockoutDuration }, time.Hour},
+		{"duration from env", "", map[string]string{"SESSION_TTL": "12h"},
+			func(c *Config) interface{} { return c.Auth.SessionTTL }, 12 * time.Hour},
+		{"bool from env", "features:\n  require_email_verification: true\n", map[string]string{"REQUIRE_EMAIL_VERIFICATION": "false"},
+			func(c *Config) interface{} { return c.Features.RequireEmailVerification }, false},
+		{"float from env", "", map[string]string{"VAT_RATE": "18.5"},
+			func(c *Config) interface{} { return c.Commerce.VATRate }, 18.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			path := ""
+			if tt.yaml != "" {
+				path = writeYAML(t, tt.yaml)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := tt.get(cfg); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadList(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  *string
+		want []string
+	}{
+		{"default", "", nil, nil},
+		{"yaml", "features:\n  mfa_required_roles: [admin]\n", nil, []string{"admin"}},
+		{"comma separated env", "", strPtr("admin,seller"), []string{"admin", "seller"}},
+		{"spaces and empty items", "", strPtr(" admin , ,seller, "), []string{"admin", "seller"}},
+		{"env replaces yaml", "features:\n  mfa_required_roles: [admin]\n", strPtr("support"), []string{"support"}},
+		{"empty env clears yaml", "features:\n  mfa_required_roles: [admin]\n", strPtr(""), []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			if tt.env != nil {
+				t.Setenv("MFA_REQUIRED_ROLES", *tt.env)
+			} else {
+				unsetenv(t, "MFA_REQUIRED_ROLES")
+			}
+			path := ""
+			if tt.yaml != "" {
+				path = writeYAML(t, tt.yaml)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := cfg.Features.MFARequiredRoles; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MFARequiredRoles = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	setRequired(t)
+	t.Setenv("SESSION_TTL", "a week")
+
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "SESSION_TTL") {
+		t.Errorf("Load = %v, want an error naming SESSION_TTL", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string // Text the error must contain, empty when the settings are valid
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing DSN", func(c *Config) { c.Database.DSN = "" }, "DSL"},
+		{"missing JWT key", func(c *Config) { c.Auth.JWTKey = "" }, "JWT_KEY"},
+		{"short JWT key", func(c *Config) { c.Auth.JWTKey = "short-key" }, "JWT_KEY"},
+		{"placeholder JWT key", func(c *Config) { c.Auth.JWTKey = "change-me" }, "JWT_KEY"},
+		{"long placeholder JWT key", func(c *Config) { c.Auth.JWTKey = "change-me-change-me-change-me-change-me" }, "JWT_KEY"},
+		{"missing TOTP key", func(c *Config) { c.Auth.TOTPEncryptionKey = "" }, "TOTP_ENCRYPTION_KEY"},
+		{"placeholder TOTP key", func(c *Config) { c.Auth.TOTPEncryptionKey = "Y2hhbmdlLW1lLWNoYW5nZS1tZS1jaGFuZ2UtbWUtMzI=" }, "TOTP_ENCRYPTION_KEY"},
+		{"short TOTP key", func(c *Config) { c.Auth.TOTPEncryptionKey = "c2hvcnQ=" }, "TOTP_ENCRYPTION_KEY"},
+		{"zero proxy hops", func(c *Config) { c.Server.ProxyHops = 0 }, "PROXY_HOPS"},
+		{"TLS certificate without key", func(c *Config) { c.Server.TLSCertFile = "cert.pem" }, "TLS_KEY_FILE"},
+		{"relative app URL", func(c *Config) { c.App.URL = "shop.example.com" }, "APP_URL"},
+		{"reset path without slash", func(c *Config) { c.App.ResetPasswordPath = "reset" }, "APP_RESET_PASSWORD_PATH"},
+		{"SMTP without sender", func(c *Config) { c.Mail.SMTPHost = "smtp.example.com" }, "MAIL_FROM"},
+		{"VAT rate over 100", func(c *Config) { c.Commerce.VATRate = 120 }, "VAT_RATE"},
+		{"unnamed OIDC provider", func(c *Config) { c.OIDC = []OIDCProvider{{}} }, "OIDC provider name"},
+		{"duplicate OIDC provider", func(c *Config) { c.OIDC = []OIDCProvider{{Name: "google"}, {Name: "google"}} }, "configured twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Database.DSN = "user:pass@tcp(localhost:3306)/shop"
+			cfg.Auth.JWTKey = testJWTKey
+			cfg.Auth.TOTPEncryptionKey = testTOTPKey
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate = nil, want errors for the missing required settings")
+	}
+	for _, name := range []string{"DSL", "JWT_KEY", "TOTP_ENCRYPTION_KEY"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Validate = %v, want it to mention %s", err, name)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// unsetenv removes a variable for the duration of the test.
+func unsetenv(t *testing.T, name string) {
+	t.Setenv(name, "")
+	os.Unsetenv(name)
+}
+
+func TestExampleConfigNeedsSecrets(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DSL", "")
+	t.Setenv("JWT_KEY", "")
+	t.Setenv("TOTP_ENCRYPTION_KEY", "")
+
+	_, err := Load("../config.example.yaml")
+	if err == nil {
+		t.Fatal("Load of the example = nil, want the missing keys reported")
+	}
+	for _, name := range []string{"JWT_KEY", "TOTP_ENCRYPTION_KEY"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Load of the example = %v, want it to mention %s", err, name)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/loginguard"
+	"e_commerce/models"
+	"e_commerce/privacy"
+	"e_commerce/rbac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const accountClosedReason = "The owner closed their account."
+
+var (
+	errAccountClosed   = errors.New("account is already closed")
+	errInvalidTransfer = errors.New("shop transfer is invalid")
+)
+
+// ReactivateAccount godoc
+// @Summary Reactivate a closed account
+// @Description Reopen an account closed by its user before it is permanently deleted. Shops closed with the account stay closed.
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param   login body models.LoginRequest true "Credentials of the closed account"
+// @Success 200 {string} string "Account reactivated"
+// @Failure 400 {string} string "Invalid input" / "Account is not closed"
+// @Failure 401 {string} string "Invalid email or password"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Failure 500 {string} string "Failed to reactivate account"
+// @Router /users/reactivate [post]
+func (h *Handler) ReactivateAccount(w http.ResponseWriter, r *http.Request) {
+	var input models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	ip := h.clientIP(r)
+	policy := h.policy
+
+	var user models.User
+	if result := database.DB.Where("email = ?", input.Email).First(&user); result.Error != nil {
+		if !loginThrottled(w, policy, nil, input.Email, ip) {
+			h.loginFailed(policy, nil, input.Email, ip, loginguard.ReasonUnknownUser)
+			http.Error(w, "Invalid email or password.", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	if loginThrottled(w, policy, &user, user.Email, ip) {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		h.loginFailed(policy, &user, user.Email, ip, loginguard.ReasonInvalidPassword)
+		http.Error(w, "Invalid email or password.", http.StatusUnauthorized)
+		return
+	}
+
+	if user.ClosedAt == nil {
+		http.Error(w, "Account is not closed.", http.StatusBadRequest)
+		return
+	}
+
+	before := user
+	user.ClosedAt = nil
+	user.DeleteAfter = nil
+	user.UpdatedAt = time.Now()
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{ActorID: user.ID, Action: "user.reactivate", EntityType: "user", EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
+		http.Error(w, "Failed to reactivate account.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account reactivated. You can log in again."})
+}
+
+// closeAccount closes the user's account: shops in transfers are handed over to the given member, the other
+// shops are closed and their products unpublished, sessions are revoked and the account is scheduled for
+// deletion after the grace period. Closure is refused while the user or a shop being closed has open orders.
+func (h *Handler) closeAccount(w http.ResponseWriter, r *http.Request, user models.User, transfers map[uint]uint, action string) {
+	now := time.Now()
+	before := user
+	deleteAfter := now.Add(time.Duration(h.cfg.Auth.DeletionGraceDays) * 24 * time.Hour)
+	user.ClosedAt = &now
+	user.DeleteAfter = &deleteAfter
+	user.UpdatedAt = now
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if before.ClosedAt != nil {
+			return errAccountClosed
+		}
+
+		var shops []models.Shop
+		if err := tx.Where("owner_id = ? AND status <> ?", user.ID, "closed").Find(&shops).Error; err != nil {
+			return err
+		}
+
+		owned := make(map[uint]bool, len(shops))
+		var closing []uint
+		for _, shop := range shops {
+			owned[shop.ID] = true
+			if _, ok := transfers[shop.ID]; !ok {
+				closing = append(closing, shop.ID)
+			}
+		}
+		for shopID := range transfers {
+			if !owned[shopID] {
+				return errInvalidTransfer
+			}
+		}
+
+		if err := privacy.CheckOpenOrders(tx, user.ID, closing); err != nil {
+			return err
+		}
+
+		for _, shop := range shops {
+			var err error
+			if newOwnerID, ok := transfers[shop.ID]; ok {
+				err = transferShop(tx, r, shop, newOwnerID)
+			} else {
+				err = closeOwnShop(tx, r, shop)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: action, EntityType: "user", EntityID: user.ID, Before: before, After: user})
+	})
+	switch {
+	case errors.Is(err, errAccountClosed):
+		http.Error(w, "Account is already closed.", http.StatusConflict)
+		return
+	case errors.Is(err, errInvalidTransfer):
+		http.Error(w, "Shops can only be transferred to an active member of a shop you own.", http.StatusBadRequest)
+		return
+	case errors.Is(err, privacy.ErrOpenOrders):
+		http.Error(w, "The account cannot be closed while orders or returns are in progress.", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to close account.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Account closed. It can be reactivated until it is deleted.",
+		"delete_after": deleteAfter,
+	})
+}
+
+// transferShop makes a member of the shop its owner and removes the previous owner's membership.
+func transferShop(tx *gorm.DB, r *http.Request, shop models.Shop, newOwnerID uint) error {
+	if newOwnerID == shop.OwnerID {
+		return errInvalidTransfer
+	}
+
+	var member models.ShopMember
+	if err := tx.Where("shop_id = ? AND user_id = ?", shop.ID, newOwnerID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidTransfer
+		}
+		return err
+	}
+
+	var newOwner models.User
+	if err := tx.First(&newOwner, newOwnerID).Error; err != nil {
+		return err
+	}
+	if newOwner.ClosedAt != nil || newOwner.SuspendedAt != nil {
+		return errInvalidTransfer
+	}
+
+	var previous models.ShopMember
+	if err := tx.Where("shop_id = ? AND user_id = ?", shop.ID, shop.OwnerID).First(&previous).Error; err == nil {
+		if err := tx.Delete(&previous).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, r, audit.Entry{Action: "shop_member.delete", EntityType: "shop_member", EntityID: previous.ID, Before: previous}); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	memberBefore := member
+	member.Role = rbac.ShopOwner
+	member.UpdatedAt = time.Now()
+	if err := tx.Save(&member).Error; err != nil {
+		return err
+	}
+	if err := audit.Record(tx, r, audit.Entry{Action: "shop_member.update", EntityType: "shop_member", EntityID: member.ID, Before: memberBefore, After: member}); err != nil {
+		return err
+	}
+
+	before := shop
+	shop.OwnerID = newOwnerID
+	if err := tx.Model(&shop).Update("owner_id", newOwnerID).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, r, audit.Entry{Action: "shop.transfer", EntityType: "shop", EntityID: shop.ID, Before: before, After: shop, Reason: accountClosedReason})
+}
+
+// closeOwnShop closes a shop whose owner closes their account and takes its products off the catalog.
+func closeOwnShop(tx *gorm.DB, r *http.Request, shop models.Shop) error {
+	before := shop
+	shop.Status = "closed"
+	shop.StatusReason = accountClosedReason
+	if err := tx.Model(&shop).Updates(map[string]interface{}{"status": shop.Status, "status_reason": shop.StatusReason}).Error; err != nil {
+		return err
+	}
+	if err := audit.Record(tx, r, audit.Entry{
+		Action: "shop.status.closed", EntityType: "shop", EntityID: shop.ID,
+		Before: before, After: shop, Reason: accountClosedReason,
+	}); err != nil {
+		return err
+	}
+
+	var products []models.Product
+	if err := tx.Where("shop_id = ? AND published = ?", shop.ID, true).Find(&products).Error; err != nil {
+		return err
+	}
+	for _, product := range products {
+		before := product
+		product.Published = false
+		if err := tx.Model(&product).Update("published", false).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, r, audit.Entry{
+			Action: "product.unpublish", EntityType: "product", EntityID: product.ID,
+			Before: before, After: product, Reason: accountClosedReason,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetAddresses godoc
+// @Summary Get my addresses
+// @Description Get the address book of the logged-in user
+// @Tags Address
+// @Produce  json
+// @Success 200 {array} models.Address
+// @Failure 500 {string} string "Failed to retrieve addresses"
+// @Router /users/profile/addresses [get]
+func (h *Handler) GetAddresses(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var addresses []models.Address
+	if result := database.DB.Where("user_id = ?", claims.UserID).Find(&addresses); result.Error != nil {
+		http.Error(w, "Failed to retrieve addresses.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(addresses)
+}
+
+// GetAddress godoc
+// @Summary Get an address
+// @Description Get an address from the address book of the logged-in user
+// @Tags Address
+// @Produce  json
+// @Param   address_id path int true "Address ID"
+// @Success 200 {object} models.Address
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Address not found"
+// @Router /users/profile/addresses/{address_id} [get]
+func (h *Handler) GetAddress(w http.ResponseWriter, r *http.Request) {
+	address, ok := findMyAddress(w, r)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(address)
+}
+
+// CreateAddress godoc
+// @Summary Add an address
+// @Description Add an address to the address book of the logged-in user
+// @Tags Address
+// @Accept  json
+// @Produce  json
+// @Param   address body models.Address true "Address"
+// @Success 201 {object} models.Address
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "Failed to create address"
+// @Router /users/profile/addresses [post]
+func (h *Handler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var address models.Address
+	err := json.NewDecoder(r.Body).Decode(&address)
+	if err != nil || !validAddress(address) {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Address{}).Where("user_id = ?", claims.UserID).Count(&count)
+	if count == 0 {
+		address.IsDefaultShipping = true
+		address.IsDefaultBilling = true
+	}
+
+	address.ID = 0
+	address.UserID = claims.UserID
+	address.CreatedAt = time.Now()
+	address.UpdatedAt = time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultAddresses(tx, address); err != nil {
+			return err
+		}
+		if err := tx.Create(&address).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "address.create", EntityType: "address", EntityID: address.ID, After: address})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create address.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(address)
+}
+
+// UpdateAddress godoc
+// @Summary Update an address
+// @Description Update an address in the address book of the logged-in user
+// @Tags Address
+// @Accept  json
+// @Produce  json
+// @Param   address_id path int true "Address ID"
+// @Param   address body models.UpdateAddressRequest true "Address, omitted default flags are kept and false clears them"
+// @Success 200 {object} models.Address
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Address not found"
+// @Failure 500 {string} string "Failed to update address"
+// @Router /users/profile/addresses/{address_id} [put]
+func (h *Handler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	address, ok := findMyAddress(w, r)
+	if !ok {
+		return
+	}
+
+	var input models.UpdateAddressRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !validAddress(input.Address) {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	before := address
+	address.Title = input.Title
+	address.FullName = input.FullName
+	address.Phone = input.Phone
+	address.Line1 = input.Line1
+	address.Line2 = input.Line2
+	address.City = input.City
+	address.State = input.State
+	address.PostalCode = input.PostalCode
+	address.Country = input.Country
+	if input.IsDefaultShipping != nil {
+		address.IsDefaultShipping = *input.IsDefaultShipping
+	}
+	if input.IsDefaultBilling != nil {
+		address.IsDefaultBilling = *input.IsDefaultBilling
+	}
+	address.UpdatedAt = time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultAddresses(tx, address); err != nil {
+			return err
+		}
+		if err := tx.Save(&address).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "address.update", EntityType: "address", EntityID: address.ID, Before: before, After: address})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update address.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(address)
+}
+
+// DeleteAddress godoc
+// @Summary Delete an address
+// @Description Delete an address from the address book of the logged-in user
+// @Tags Address
+// @Param   address_id path int true "Address ID"
+// @Success 204 {string} string "Address deleted successfully"
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Address not found"
+// @Failure 500 {string} string "Failed to delete address"
+// @Router /users/profile/addresses/{address_id} [delete]
+func (h *Handler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	address, ok := findMyAddress(w, r)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&address).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "address.delete", EntityType: "address", EntityID: address.ID, Before: address})
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete address.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findMyAddress loads the address in the path if it belongs to the logged-in user.
+func findMyAddress(w http.ResponseWriter, r *http.Request) (models.Address, bool) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+
+	var address models.Address
+	addressID, err := strconv.Atoi(params["address_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return address, false
+	}
+
+	if result := database.DB.Where("user_id = ?", claims.UserID).First(&address, addressID); result.Error != nil {
+		http.Error(w, "Address not found.", http.StatusNotFound)
+		return address, false
+	}
+
+	return address, true
+}
+
+func validAddress(a models.Address) bool {
+	return a.Title != "" && validOrderAddress(a)
+}
+
+// validOrderAddress checks the fields that are copied onto an order.
+func validOrderAddress(a models.Address) bool {
+	return a.FullName != "" && a.Phone != "" && a.Line1 != "" &&
+		a.City != "" && a.PostalCode != "" && a.Country != ""
+}
+
+// clearDefaultAddresses removes the default flags that the given address is taking over from the user's other addresses.
+func clearDefaultAddresses(tx *gorm.DB, address models.Address) error {
+	others := tx.Model(&models.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID)
+	if address.IsDefaultShipping {
+		if err := others.Session(&gorm.Session{}).Update("is_default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if err := others.Session(&gorm.Session{}).Update("is_default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveOrderAddress returns the requested address of the user, or the default one when no id is given.
+func resolveOrderAddress(userID, addressID uint, defaultColumn string) (models.Address, error) {
+	var address models.Address
+	query := database.DB.Where("user_id = ?", userID)
+	if addressID != 0 {
+		return address, query.First(&address, addressID).Error
+	}
+	return address, query.Where(defaultColumn+" = ?", true).First(&address).Error
+}
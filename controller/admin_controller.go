@@ -0,0 +1,521 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// AdminGetUsers godoc
+// @Summary List users
+// @Description Search users by name or email and filter them by role and status (active, suspended)
+// @Tags Admin
+// @Produce  json
+// @Param   q query string false "Name, surname or email contains"
+// @Param   role query string false "Role"
+// @Param   status query string false "active or suspended"
+// @Param   page query int false "Page, starting from 1"
+// @Param   per_page query int false "Items per page, at most 100"
+// @Success 200 {object} models.Page
+// @Failure 500 {string} string "Failed to retrieve users"
+// @Router /admin/users [get]
+func (h *Handler) AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := database.DB.Model(&models.User{})
+
+	if s := q.Get("q"); s != "" {
+		like := "%" + s + "%"
+		query = query.Where("name LIKE ? OR surname LIKE ? OR email LIKE ?", like, like, like)
+	}
+	if role := q.Get("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch q.Get("status") {
+	case "active":
+		query = query.Where("suspended_at IS NULL")
+	case "suspended":
+		query = query.Where("suspended_at IS NOT NULL")
+	}
+
+	var users []models.UserSummary
+	writePage(w, r, query.Order("id DESC"), &users, "Failed to retrieve users.")
+}
+
+// AdminSuspendUser godoc
+// @Summary Suspend a user
+// @Description Suspend a user and end their sessions. Suspended users cannot log in.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Param   body body models.SuspendRequest true "Reason"
+// @Success 200 {string} string "User suspended"
+// @Failure 400 {string} string "Invalid input" / "You cannot suspend yourself"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to suspend user"
+// @Router /admin/users/{user_id}/suspend [post]
+func (h *Handler) AdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.SuspendRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, mux.Vars(r)["user_id"]); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if user.ID == claims.UserID {
+		http.Error(w, "You cannot suspend yourself.", http.StatusBadRequest)
+		return
+	}
+
+	before := user
+	now := time.Now()
+	user.SuspendedAt = &now
+	user.SuspensionReason = input.Reason
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"suspended_at": now, "suspension_reason": input.Reason}).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{
+			Action: "user.suspend", EntityType: "user", EntityID: user.ID,
+			Before: before, After: user, Reason: input.Reason,
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to suspend user.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User suspended."})
+}
+
+// AdminReactivateUser godoc
+// @Summary Reactivate a user
+// @Description Lift the suspension of a user
+// @Tags Admin
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Success 200 {string} string "User reactivated"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to reactivate user"
+// @Router /admin/users/{user_id}/reactivate [post]
+func (h *Handler) AdminReactivateUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if result := database.DB.First(&user, mux.Vars(r)["user_id"]); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	before := user
+	user.SuspendedAt = nil
+	user.SuspensionReason = ""
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"suspended_at": nil, "suspension_reason": ""}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.reactivate", EntityType: "user", EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
+		http.Error(w, "Failed to reactivate user.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User reactivated."})
+}
+
+// AdminGetShops godoc
+// @Summary List shops
+// @Description Search shops by name and filter them by status (active, suspended, closed) and approval. Use approval=submitted for the review queue.
+// @Tags Admin
+// @Produce  json
+// @Param   q query string false "Name contains"
+// @Param   status query string false "active, suspended or closed"
+// @Param   approval query string false "draft, submitted, approved or rejected"
+// @Param   owner_id query int false "Owner user ID"
+// @Param   page query int false "Page, starting from 1"
+// @Param   per_page query int false "Items per page, at most 100"
+// @Success 200 {object} models.Page
+// @Failure 500 {string} string "Failed to retrieve shops"
+// @Router /admin/shops [get]
+func (h *Handler) AdminGetShops(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := database.DB.Model(&models.Shop{})
+
+	if s := q.Get("q"); s != "" {
+		query = query.Where("name LIKE ?", "%"+s+"%")
+	}
+	if status := q.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if approval := q.Get("approval"); approval != "" {
+		query = query.Where("approval = ?", approval)
+	}
+	if ownerID := q.Get("owner_id"); ownerID != "" {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+
+	var shops []models.Shop
+	writePage(w, r, query.Order("id DESC"), &shops, "Failed to retrieve shops.")
+}
+
+// AdminSetShopStatus godoc
+// @Summary Suspend, close or reactivate a shop
+// @Description Change the status of a shop. Suspended and closed shops and their products are hidden from customers; closed shops cannot be reopened.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param   shop_id path int true "Shop ID"
+// @Param   body body models.ShopStatusRequest true "Status and reason"
+// @Success 200 {object} models.Shop
+// @Failure 400 {string} string "Invalid input" / "Closed shops cannot be reopened"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to update shop"
+// @Router /admin/shops/{shop_id}/status [put]
+func (h *Handler) AdminSetShopStatus(w http.ResponseWriter, r *http.Request) {
+	var input models.ShopStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || (input.Status != "active" && input.Status != "suspended" && input.Status != "closed") {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+	if input.Status != "active" && strings.TrimSpace(input.Reason) == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var shop models.Shop
+	if result := database.DB.First(&shop, mux.Vars(r)["shop_id"]); result.Error != nil {
+		http.Error(w, "Shop not found.", http.StatusNotFound)
+		return
+	}
+
+	if shop.Status == "closed" {
+		http.Error(w, "Closed shops cannot be reopened.", http.StatusBadRequest)
+		return
+	}
+
+	before := shop
+	shop.Status = input.Status
+	shop.StatusReason = input.Reason
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&shop).Updates(map[string]interface{}{"status": input.Status, "status_reason": input.Reason}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{
+			Action: "shop.status." + input.Status, EntityType: "shop", EntityID: shop.ID,
+			Before: before, After: shop, Reason: input.Reason,
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update shop.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shop)
+}
+
+// AdminGetProducts godoc
+// @Summary List products
+// @Description Search all products by name, including unpublished ones and those of suspended shops
+// @Tags Admin
+// @Produce  json
+// @Param   q query string false "Name contains"
+// @Param   shop_id query int false "Shop ID"
+// @Param   published query bool false "Published or unpublished only"
+// @Param   page query int false "Page, starting from 1"
+// @Param   per_page query int false "Items per page, at most 100"
+// @Success 200 {object} models.Page
+// @Failure 500 {string} string "Failed to retrieve products"
+// @Router /admin/products [get]
+func (h *Handler) AdminGetProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := database.DB.Model(&models.Product{})
+
+	if s := q.Get("q"); s != "" {
+		query = query.Where("name LIKE ?", "%"+s+"%")
+	}
+	if shopID := q.Get("shop_id"); shopID != "" {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if published, err := strconv.ParseBool(q.Get("published")); err == nil {
+		query = query.Where("published = ?", published)
+	}
+
+	var products []models.Product
+	writePage(w, r, query.Order("id DESC"), &products, "Failed to retrieve products.")
+}
+
+// AdminUnpublishProduct godoc
+// @Summary Unpublish a product
+// @Description Hide a product from customers. The reason is kept in the audit log.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param   product_id path int true "Product ID"
+// @Param   body body models.SuspendRequest true "Reason"
+// @Success 200 {object} models.Product
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Failed to update product"
+// @Router /admin/products/{product_id}/unpublish [post]
+func (h *Handler) AdminUnpublishProduct(w http.ResponseWriter, r *http.Request) {
+	var input models.SuspendRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	setProductPublished(w, r, false, input.Reason)
+}
+
+// AdminPublishProduct godoc
+// @Summary Republish a product
+// @Description Make an unpublished product visible to customers again
+// @Tags Admin
+// @Produce  json
+// @Param   product_id path int true "Product ID"
+// @Success 200 {object} models.Product
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Failed to update product"
+// @Router /admin/products/{product_id}/publish [post]
+func (h *Handler) AdminPublishProduct(w http.ResponseWriter, r *http.Request) {
+	setProductPublished(w, r, true, "")
+}
+
+// AdminGetOrders godoc
+// @Summary List orders
+// @Description List orders filtered by status, shop, customer and creation date
+// @Tags Admin
+// @Produce  json
+// @Param   q query string false "Order ID or customer email"
+// @Param   status query string false "Status"
+// @Param   shop_id query int false "Shop ID"
+// @Param   user_id query int false "Customer user ID"
+// @Param   from query string false "Created at or after, YYYY-MM-DD or RFC 3339 time"
+// @Param   to query string false "Created up to, YYYY-MM-DD (inclusive) or RFC 3339 time (exclusive)"
+// @Param   page query int false "Page, starting from 1"
+// @Param   per_page query int false "Items per page, at most 100"
+// @Success 200 {object} models.Page
+// @Failure 400 {string} string "Invalid date"
+// @Failure 500 {string} string "Failed to retrieve orders"
+// @Router /admin/orders [get]
+func (h *Handler) AdminGetOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := database.DB.Model(&models.Order{})
+
+	if s := q.Get("q"); s != "" {
+		if id, err := strconv.Atoi(s); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("user_id IN (?)", database.DB.Model(&models.User{}).Select("id").Where("email LIKE ?", "%"+s+"%"))
+		}
+	}
+	if status := q.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if shopID := q.Get("shop_id"); shopID != "" {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if userID := q.Get("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	query, ok := filterCreatedAt(w, r, query)
+	if !ok {
+		return
+	}
+
+	var orders []models.Order
+	writePage(w, r, query.Order("id DESC"), &orders, "Failed to retrieve orders.")
+}
+
+// AdminSetOrderStatus godoc
+// @Summary Override the status of an order
+// @Description Set the status of a shop order regardless of the seller. The parent order status follows its shop orders. Delivered and refunded orders are settled in the ledger and cannot be overridden; refund them through a return.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Param   body body models.AdminOrderStatusRequest true "Status and reason"
+// @Success 200 {object} models.Order
+// @Failure 400 {string} string "Invalid input" / "Update the shop orders of this order instead"
+// @Failure 404 {string} string "Order not found"
+// @Failure 409 {string} string "Settled orders cannot be overridden"
+// @Failure 500 {string} string "Failed to update order status"
+// @Router /admin/orders/{order_id}/status [put]
+func (h *Handler) AdminSetOrderStatus(w http.ResponseWriter, r *http.Request) {
+	var input models.AdminOrderStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !validOrderStatus(input.Status) || strings.TrimSpace(input.Reason) == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var order models.Order
+	if result := database.DB.First(&order, mux.Vars(r)["order_id"]); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
+	}
+
+	var subOrders int64
+	database.DB.Model(&models.Order{}).Where("parent_id = ?", order.ID).Count(&subOrders)
+	if subOrders > 0 {
+		http.Error(w, "Update the shop orders of this order instead.", http.StatusBadRequest)
+		return
+	}
+
+	var before models.Order
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		// The sale of a delivered order is already in the ledger; moving it back would leave the
+		// shop credited for an order it no longer sold.
+		if settledOrder(order.Status) && input.Status != order.Status {
+			return errOrderSettled
+		}
+
+		before = order
+		if err := h.applyOrderStatus(tx, &order, input.Status); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{
+			Action: "order.status." + input.Status, EntityType: "order", EntityID: order.ID,
+			Before: before, After: order, Reason: input.Reason,
+		})
+	})
+	if err == errOrderSettled {
+		http.Error(w, "Settled orders cannot be overridden.", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update order status.", http.StatusInternalServerError)
+		return
+	}
+
+	notification.Notify(database.DB, order.UserID, "order_status", map[string]interface{}{"Order": order})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(order)
+}
+
+func setProductPublished(w http.ResponseWriter, r *http.Request, published bool, reason string) {
+	var product models.Product
+	if result := database.DB.First(&product, mux.Vars(r)["product_id"]); result.Error != nil {
+		http.Error(w, "Product not found.", http.StatusNotFound)
+		return
+	}
+
+	action := "product.unpublish"
+	if published {
+		action = "product.publish"
+	}
+
+	before := product
+	product.Published = published
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Update("published", published).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{
+			Action: action, EntityType: "product", EntityID: product.ID,
+			Before: before, After: product, Reason: reason,
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update product.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(product)
+}
+
+// filterCreatedAt limits the query to rows created in the from and to query parameters,
+// given as dates (YYYY-MM-DD) or RFC 3339 timestamps. A date in to includes the whole day.
+func filterCreatedAt(w http.ResponseWriter, r *http.Request, query *gorm.DB) (*gorm.DB, bool) {
+	for _, param := range []string{"from", "to"} {
+		s := r.URL.Query().Get(param)
+		if s == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			day, dayErr := time.ParseInLocation("2006-01-02", s, time.Local)
+			if dayErr != nil {
+				http.Error(w, "Invalid date.", http.StatusBadRequest)
+				return query, false
+			}
+			t = day
+			if param == "to" {
+				t = day.AddDate(0, 0, 1)
+			}
+		}
+
+		if param == "from" {
+			query = query.Where("created_at >= ?", t)
+		} else {
+			query = query.Where("created_at < ?", t)
+		}
+	}
+	return query, true
+}
+
+// writePage writes one page of the query results, read from the page and per_page query parameters.
+func writePage(w http.ResponseWriter, r *http.Request, query *gorm.DB, dest interface{}, failure string) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		http.Error(w, failure, http.StatusInternalServerError)
+		return
+	}
+	if err := query.Session(&gorm.Session{}).Offset((page - 1) * perPage).Limit(perPage).Find(dest).Error; err != nil {
+		http.Error(w, failure, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.Page{Items: dest, Page: page, PerPage: perPage, Total: total})
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"e_commerce/database"
+	"e_commerce/models"
+	"net/http"
+	"strings"
+)
+
+// GetAuditLogs godoc
+// @Summary Search the audit log
+// @Description List audit log entries, newest first, filtered by actor, entity, action, request and creation time
+// @Tags Admin
+// @Produce  json
+// @Param   actor_id query int false "User ID of the actor, 0 for the system and signed links"
+// @Param   entity_type query string false "Entity type, e.g. product"
+// @Param   entity_id query int false "Entity ID, used with entity_type"
+// @Param   action query string false "Action, or an action prefix ending in a dot such as order.status."
+// @Param   request_id query string false "Request ID from the X-Request-ID header"
+// @Param   from query string false "Created at or after, YYYY-MM-DD or RFC 3339 time"
+// @Param   to query string false "Created up to, YYYY-MM-DD (inclusive) or RFC 3339 time (exclusive)"
+// @Param   page query int false "Page, starting from 1"
+// @Param   per_page query int false "Items per page, at most 100"
+// @Success 200 {object} models.Page
+// @Failure 400 {string} string "Invalid date"
+// @Failure 500 {string} string "Failed to retrieve audit log"
+// @Router /admin/audit-logs [get]
+func (h *Handler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := database.DB.Model(&models.AuditLog{})
+
+	if actorID := q.Get("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if entityType := q.Get("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := q.Get("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if action := q.Get("action"); strings.HasSuffix(action, ".") {
+		query = query.Where("action LIKE ?", strings.ReplaceAll(action, "_", `\_`)+"%")
+	} else if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if requestID := q.Get("request_id"); requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
+
+	query, ok := filterCreatedAt(w, r, query)
+	if !ok {
+		return
+	}
+
+	var logs []models.AuditLog
+	writePage(w, r, query.Order("id DESC"), &logs, "Failed to retrieve audit log.")
+}
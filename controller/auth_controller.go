@@ -1,58 +1,88 @@
 package controller
 
 import (
+	"e_commerce/audit"
 	"e_commerce/database"
+	"e_commerce/loginguard"
 	"e_commerce/models"
+	"e_commerce/notification"
 	"encoding/json"
+	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_KEY"))
-
 // RegisterHandler godoc
 // @Summary Register a new user
 // @Description Register a new user with username, password, email, and role
 // @Tags User
 // @Accept  json
 // @Produce  json
-// @Param   user body models.User true "User"
+// @Param   user body models.RegisterRequest true "User"
 // @Success 201 {string} string "User registered successfully"
 // @Failure 400 {string} string "Invalid input"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 500 {string} string "Failed to hash password" / "Failed to create user"
 // @Router /users/register [post]
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var input models.RegisterRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
 		http.Error(w, "Invalid input.", http.StatusBadRequest)
 		return
 	}
 
-	if user.Role == "" {
+	if input.Role == "" {
 		http.Error(w, "Role is Required.", http.StatusBadRequest)
 		return
 	}
 
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	var role models.Role
+	if result := database.DB.Where("name = ? AND self_signup = ?", input.Role, true).First(&role); result.Error != nil {
+		http.Error(w, "Invalid role.", http.StatusBadRequest)
+		return
+	}
+
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		http.Error(w, "Failed to hash password.", http.StatusInternalServerError)
 		return
 	}
 
-	user.Password = string(hashedPass)
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
+	now := time.Now()
+	user := models.User{
+		Name:               input.Name,
+		Surname:            input.Surname,
+		Email:              input.Email,
+		Password:           string(hashedPass),
+		Role:               role.Name,
+		Locale:             input.Locale,
+		VerificationSentAt: &now, // Doğrulama ile açılan hesapları önceki hesaplardan ayırır
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if !notification.SupportedLocale(user.Locale) {
+		user.Locale = notification.DefaultLocale
+	}
 
-	if result := database.DB.Create(&user); result.Error != nil {
-		http.Error(w, "Failed to create user: "+result.Error.Error(), http.StatusInternalServerError)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.create", EntityType: "user", EntityID: user.ID, After: user})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create user.", http.StatusInternalServerError)
 		return
 	}
 
+	if err := h.sendVerificationEmail(&user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
 	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully."})
 
 }
@@ -64,12 +94,13 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Param   login body models.LoginRequest true "Login Request"
-// @Success 200 {string} string "Logged in successfully"
+// @Success 200 {string} string "Logged in successfully, or an MFA challenge token when 2FA is enabled"
 // @Failure 400 {string} string "Invalid input"
 // @Failure 401 {string} string "Invalid email or password"
+// @Failure 429 {string} string "Too many failed login attempts" / "Account is temporarily locked"
 // @Failure 500 {string} string "Internal server error"
 // @Router /users/login [post]
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var reqUser struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -80,30 +111,69 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := h.clientIP(r)
+	policy := h.policy
+
 	var user models.User
 	if result := database.DB.Where("email = ?", reqUser.Email).First(&user); result.Error != nil {
-		http.Error(w, "Invalid email or password.", http.StatusUnauthorized)
+		if !loginThrottled(w, policy, nil, reqUser.Email, ip) {
+			h.loginFailed(policy, nil, reqUser.Email, ip, loginguard.ReasonUnknownUser)
+			http.Error(w, "Invalid email or password.", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	if loginThrottled(w, policy, &user, user.Email, ip) {
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(reqUser.Password))
 	if err != nil {
+		h.loginFailed(policy, &user, user.Email, ip, loginguard.ReasonInvalidPassword)
 		http.Error(w, "Invalid email or password.", http.StatusUnauthorized)
 		return
 	}
 
-	expirationTime := time.Now().Add(168 * time.Hour)
+	if user.TOTPEnabled {
+		mfaToken, err := h.issueMFAChallenge(user)
+		if err != nil {
+			http.Error(w, "Failed to create token.", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	loginSucceeded(&user, ip, loginguard.ReasonPassword)
+	h.issueLoginToken(w, user)
+}
+
+// issueLoginToken writes a signed session token for the user to the response, or 403 when the account is suspended or closed.
+func (h *Handler) issueLoginToken(w http.ResponseWriter, user models.User) {
+	if user.SuspendedAt != nil {
+		http.Error(w, "Your account is suspended.", http.StatusForbidden)
+		return
+	}
+	if user.ClosedAt != nil {
+		http.Error(w, "Your account is closed. Reactivate it to log in again.", http.StatusForbidden)
+		return
+	}
+
+	expirationTime := time.Now().Add(h.cfg.Auth.SessionTTL)
 	claims := &models.Claims{
 		Email:  user.Email,
 		UserID: user.ID,
 		Role:   user.Role,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
+			IssuedAt:  time.Now().Unix(),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString(jwtKey)
+	tokenStr, err := token.SignedString(h.jwtKey)
 	if err != nil {
 		http.Error(w, "Failed to create token.", http.StatusInternalServerError)
 		return
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"e_commerce/config"
+	"e_commerce/loginguard"
+	"e_commerce/oidc"
+	"e_commerce/totp"
+	"net/http"
+	"sync"
+)
+
+// Handler serves the API endpoints with the settings it is built with.
+type Handler struct {
+	cfg       *config.Config
+	jwtKey    []byte // Oturum token'larını imzalar
+	policy    loginguard.Policy
+	proxyHops int
+	secrets   *totp.Cipher
+	providers oidc.Providers
+
+	// background tracks the work handlers leave running after they respond.
+	background sync.WaitGroup
+}
+
+// New returns the handlers for the configuration. secrets encrypts the stored 2FA secrets.
+func New(cfg *config.Config, secrets *totp.Cipher) *Handler {
+	return &Handler{
+		cfg:       cfg,
+		jwtKey:    []byte(cfg.Auth.JWTKey),
+		policy:    loginguard.NewPolicy(cfg.Login),
+		proxyHops: loginguard.ProxyHops(cfg.Server),
+		secrets:   secrets,
+		providers: oidc.NewProviders(cfg.OIDC),
+	}
+}
+
+// Wait blocks until the work started by handlers in the background is done. The server calls it on shutdown,
+// before the database is closed.
+func (h *Handler) Wait() error {
+	h.background.Wait()
+	return nil
+}
+
+// tokenKey returns the signing key of a single-purpose token, derived from the JWT key when it is used.
+// Each subject has its own key, separate from the login key, so a verification link, unlock link,
+// MFA challenge or OIDC flow cookie can never be used as a login token.
+func (h *Handler) tokenKey(subject string) []byte {
+	return append([]byte(subject+":"), h.jwtKey...)
+}
+
+// clientIP returns the address of the client, taken from X-Forwarded-For when the server trusts its proxies.
+func (h *Handler) clientIP(r *http.Request) string {
+	return loginguard.ClientIP(r, h.proxyHops)
+}
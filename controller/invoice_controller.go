@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"e_commerce/database"
+	"e_commerce/invoice"
+	"e_commerce/models"
+	"e_commerce/pdf"
+	"e_commerce/rbac"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// GetOrderInvoice godoc
+// @Summary Download the invoice of an order
+// @Description Download the invoice of an order as PDF. An order with items from several shops gets one invoice page per shop.
+// @Tags Orders
+// @Produce  application/pdf
+// @Param   order_id path int true "Order ID"
+// @Success 200 {file} file "Invoice PDF"
+// @Failure 400 {string} string "Invalid id" / "Order has no invoice"
+// @Failure 404 {string} string "Order not found"
+// @Failure 409 {string} string "Order is not confirmed yet"
+// @Failure 500 {string} string "Failed to issue invoice"
+// @Router /orders/{order_id}/invoice.pdf [get]
+func (h *Handler) GetOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	orderID, err := strconv.Atoi(params["order_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var order models.Order
+	if result := database.DB.Preload("SubOrders").First(&order, orderID); result.Error != nil || !canViewInvoice(claims, order) {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
+	}
+
+	shopOrders := []models.Order{order}
+	if order.ShopID == nil {
+		shopOrders = order.SubOrders
+	}
+
+	doc := pdf.New()
+	issued, unconfirmed := 0, 0
+	for _, shopOrder := range shopOrders {
+		if shopOrder.Status == "cancelled" {
+			continue
+		}
+
+		inv, err := invoice.Issue(database.DB, shopOrder, h.cfg.Commerce.VATRate)
+		if errors.Is(err, invoice.ErrNotConfirmed) {
+			unconfirmed++
+			continue
+		}
+		if err != nil {
+			http.Error(w, "Failed to issue invoice.", http.StatusInternalServerError)
+			return
+		}
+		invoice.Render(doc, inv)
+		issued++
+	}
+
+	if issued == 0 && unconfirmed > 0 {
+		http.Error(w, "Order is not confirmed yet.", http.StatusConflict)
+		return
+	}
+	if issued == 0 {
+		http.Error(w, "Order has no invoice.", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="order-%d-invoice.pdf"`, order.ID))
+	w.WriteHeader(http.StatusOK)
+	doc.WriteTo(w)
+}
+
+// canViewInvoice reports whether the user placed the order or owns the shop of the shop order.
+func canViewInvoice(claims *models.Claims, order models.Order) bool {
+	if order.UserID == claims.UserID || rbac.Has(database.DB, claims.Role, rbac.OrdersReadAny) {
+		return true
+	}
+	if order.ShopID == nil {
+		return false
+	}
+
+	var member models.ShopMember
+	return database.DB.Where("shop_id = ? AND user_id = ?", *order.ShopID, claims.UserID).First(&member).Error == nil &&
+		rbac.ShopCan(member.Role, rbac.ShopView)
+}
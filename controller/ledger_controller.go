@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/ledger"
+	"e_commerce/models"
+	"e_commerce/rbac"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetShopBalance godoc
+// @Summary Get my shop balance
+// @Description Get the amount the platform owes to the logged-in seller's shop
+// @Tags Payouts
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} map[string]float64
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to calculate balance"
+// @Router /shop/balance [get]
+func (h *Handler) GetShopBalance(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopFinance)
+	if !ok {
+		return
+	}
+
+	balance, err := ledger.Balance(database.DB, shop.ID)
+	if err != nil {
+		http.Error(w, "Failed to calculate balance.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]float64{"balance": balance})
+}
+
+// GetShopStatement godoc
+// @Summary Get my shop statement
+// @Description Get the sales, commissions, refunds and payouts of the logged-in seller's shop. Defaults to the last 30 days.
+// @Tags Payouts
+// @Produce  json
+// @Param   from query string false "Start date (YYYY-MM-DD)"
+// @Param   to query string false "End date (YYYY-MM-DD)"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.StatementLine
+// @Failure 400 {string} string "Invalid date"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to retrieve statement"
+// @Router /shop/statement [get]
+func (h *Handler) GetShopStatement(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopFinance)
+	if !ok {
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid date.", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "Invalid date.", http.StatusBadRequest)
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	lines, err := ledger.Statement(database.DB, shop.ID, from, to)
+	if err != nil {
+		http.Error(w, "Failed to retrieve statement.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(lines)
+}
+
+// GetShopPayouts godoc
+// @Summary Get my shop payouts
+// @Description Get the payouts made to the logged-in seller's shop
+// @Tags Payouts
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.Payout
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to retrieve payouts"
+// @Router /shop/payouts [get]
+func (h *Handler) GetShopPayouts(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopFinance)
+	if !ok {
+		return
+	}
+
+	var payouts []models.Payout
+	if result := database.DB.Where("shop_id = ?", shop.ID).Order("created_at DESC").Find(&payouts); result.Error != nil {
+		http.Error(w, "Failed to retrieve payouts.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payouts)
+}
+
+// GetCommissionRules godoc
+// @Summary Get commission rules
+// @Description Get the platform commission rules
+// @Tags Payouts
+// @Produce  json
+// @Success 200 {array} models.CommissionRule
+// @Failure 500 {string} string "Failed to retrieve commission rules"
+// @Router /admin/commission-rules [get]
+func (h *Handler) GetCommissionRules(w http.ResponseWriter, r *http.Request) {
+	var rules []models.CommissionRule
+	if result := database.DB.Find(&rules); result.Error != nil {
+		http.Error(w, "Failed to retrieve commission rules.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rules)
+}
+
+// CreateCommissionRule godoc
+// @Summary Create a commission rule
+// @Description Set the platform commission for a shop, a category or a category within a shop
+// @Tags Payouts
+// @Accept  json
+// @Produce  json
+// @Param   rule body models.CommissionRule true "Commission Rule"
+// @Success 201 {object} models.CommissionRule
+// @Failure 400 {string} string "Invalid input"
+// @Failure 500 {string} string "Failed to create commission rule"
+// @Router /admin/commission-rules [post]
+func (h *Handler) CreateCommissionRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.CommissionRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil || rule.Rate < 0 || rule.Rate > 100 {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	rule.ID = 0
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "commission_rule.create", EntityType: "commission_rule", EntityID: rule.ID, After: rule})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create commission rule.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// DeleteCommissionRule godoc
+// @Summary Delete a commission rule
+// @Description Delete a platform commission rule
+// @Tags Payouts
+// @Param   rule_id path int true "Commission Rule ID"
+// @Success 204 {string} string "Commission rule deleted successfully"
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Commission rule not found"
+// @Failure 500 {string} string "Failed to delete commission rule"
+// @Router /admin/commission-rules/{rule_id} [delete]
+func (h *Handler) DeleteCommissionRule(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	ruleID, err := strconv.Atoi(params["rule_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var rule models.CommissionRule
+	if result := database.DB.First(&rule, ruleID); result.Error != nil {
+		http.Error(w, "Commission rule not found.", http.StatusNotFound)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "commission_rule.delete", EntityType: "commission_rule", EntityID: rule.ID, Before: rule})
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete commission rule.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunPayouts godoc
+// @Summary Run a payout batch
+// @Description Pay out every shop with a positive balance now instead of waiting for the scheduler
+// @Tags Payouts
+// @Produce  json
+// @Success 200 {object} models.PayoutBatch
+// @Failure 500 {string} string "Failed to run payouts"
+// @Router /admin/payouts/run [post]
+func (h *Handler) RunPayouts(w http.ResponseWriter, r *http.Request) {
+	batch, err := ledger.RunPayouts(database.DB, 0)
+	if batch.ID != 0 {
+		// Payouts are sent one by one outside a transaction, so the batch is recorded even when some of them failed.
+		if err := audit.Record(database.DB, r, audit.Entry{Action: "payout_batch.create", EntityType: "payout_batch", EntityID: batch.ID, After: batch}); err != nil {
+			log.Printf("Failed to record payout batch %d in the audit log: %v", batch.ID, err)
+		}
+	}
+	if err != nil {
+		http.Error(w, "Failed to run payouts.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetPayoutBatches godoc
+// @Summary Get payout batches
+// @Description Get the settlement batches with their payouts
+// @Tags Payouts
+// @Produce  json
+// @Success 200 {array} models.PayoutBatch
+// @Failure 500 {string} string "Failed to retrieve payout batches"
+// @Router /admin/payouts [get]
+func (h *Handler) GetPayoutBatches(w http.ResponseWriter, r *http.Request) {
+	var batches []models.PayoutBatch
+	if result := database.DB.Preload("Payouts").Order("created_at DESC").Find(&batches); result.Error != nil {
+		http.Error(w, "Failed to retrieve payout batches.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batches)
+}
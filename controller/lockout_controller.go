@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/loginguard"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	unlockSubject      = "account-unlock"
+	loginAttemptsLimit = 50
+)
+
+// UnlockAccount godoc
+// @Summary Unlock account
+// @Description Lift a lockout with the token from the account locked email
+// @Tags User
+// @Produce  json
+// @Param   token query string true "Unlock token"
+// @Success 200 {string} string "Account unlocked"
+// @Failure 400 {string} string "Invalid or expired unlock link"
+// @Failure 500 {string} string "Failed to unlock account"
+// @Router /users/unlock [get]
+func (h *Handler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	claims := &models.AccountUnlockClaims{}
+	token, err := jwt.ParseWithClaims(r.URL.Query().Get("token"), claims, func(t *jwt.Token) (interface{}, error) {
+		return h.tokenKey(unlockSubject), nil
+	})
+	if err != nil || !token.Valid || claims.Subject != unlockSubject {
+		http.Error(w, "Invalid or expired unlock link.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil ||
+		user.LockedUntil == nil || user.LockedUntil.Unix() != claims.LockedUntil {
+		http.Error(w, "Invalid or expired unlock link.", http.StatusBadRequest)
+		return
+	}
+
+	after := user
+	after.LockedUntil = nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := loginguard.Unlock(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{ActorID: user.ID, Action: "user.unlock", EntityType: "user", EntityID: user.ID, Before: user, After: after})
+	})
+	if err != nil {
+		http.Error(w, "Failed to unlock account.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account unlocked."})
+}
+
+// AdminUnlockUser godoc
+// @Summary Unlock a user
+// @Description Lift the lockout of a user account
+// @Tags Admin
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Success 200 {string} string "Account unlocked"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to unlock account"
+// @Router /admin/users/{user_id}/unlock [post]
+func (h *Handler) AdminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if result := database.DB.First(&user, mux.Vars(r)["user_id"]); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	after := user
+	after.LockedUntil = nil
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := loginguard.Unlock(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.unlock", EntityType: "user", EntityID: user.ID, Before: user, After: after})
+	})
+	if err != nil {
+		http.Error(w, "Failed to unlock account.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account unlocked."})
+}
+
+// GetLoginAttempts godoc
+// @Summary Get login history
+// @Description Get the latest login attempts of the logged-in user
+// @Tags User
+// @Produce  json
+// @Success 200 {array} models.LoginAttempt
+// @Failure 500 {string} string "Failed to fetch login attempts"
+// @Router /users/profile/login-attempts [get]
+func (h *Handler) GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	writeLoginAttempts(w, claims.UserID)
+}
+
+// GetUserLoginAttempts godoc
+// @Summary Get login history of a user
+// @Description Get the latest login attempts of a user
+// @Tags Admin
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Success 200 {array} models.LoginAttempt
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 500 {string} string "Failed to fetch login attempts"
+// @Router /admin/users/{user_id}/login-attempts [get]
+func (h *Handler) GetUserLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID.", http.StatusBadRequest)
+		return
+	}
+	writeLoginAttempts(w, uint(userID))
+}
+
+func writeLoginAttempts(w http.ResponseWriter, userID uint) {
+	var attempts []models.LoginAttempt
+	if result := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(loginAttemptsLimit).Find(&attempts); result.Error != nil {
+		http.Error(w, "Failed to fetch login attempts.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attempts)
+}
+
+// loginThrottled records a blocked attempt and writes a 429 response when the client has to wait before logging in again.
+func loginThrottled(w http.ResponseWriter, policy loginguard.Policy, user *models.User, email, ip string) bool {
+	now := time.Now()
+	wait, locked := policy.Wait(database.DB, user, ip, now)
+	if wait <= 0 {
+		return false
+	}
+
+	var userID *uint
+	reason := loginguard.ReasonThrottled
+	if user != nil {
+		userID = &user.ID
+	}
+	if locked {
+		reason = loginguard.ReasonLocked
+	}
+	loginguard.Record(database.DB, userID, email, ip, false, reason, now)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	if locked {
+		http.Error(w, "Account is temporarily locked. Check your email to unlock it.", http.StatusTooManyRequests)
+		return true
+	}
+	http.Error(w, "Too many failed login attempts. Try again later.", http.StatusTooManyRequests)
+	return true
+}
+
+// loginFailed records a failed attempt and emails an unlock link when it locks the account.
+func (h *Handler) loginFailed(policy loginguard.Policy, user *models.User, email, ip, reason string) {
+	locked, err := policy.Fail(database.DB, user, email, ip, reason, time.Now())
+	if err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", email, err)
+		return
+	}
+
+	if locked {
+		if err := h.sendUnlockEmail(user); err != nil {
+			log.Printf("Failed to send unlock email to user %d: %v", user.ID, err)
+		}
+	}
+}
+
+func loginSucceeded(user *models.User, ip, reason string) {
+	if err := loginguard.Succeed(database.DB, user, ip, reason, time.Now()); err != nil {
+		log.Printf("Failed to record login of user %d: %v", user.ID, err)
+	}
+}
+
+func (h *Handler) sendUnlockEmail(user *models.User) error {
+	claims := &models.AccountUnlockClaims{
+		UserID:      user.ID,
+		LockedUntil: user.LockedUntil.Unix(),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   unlockSubject,
+			ExpiresAt: user.LockedUntil.Unix(),
+		},
+	}
+
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.tokenKey(unlockSubject))
+	if err != nil {
+		return err
+	}
+
+	return notification.Enqueue(database.DB, user.ID, "account_locked", map[string]interface{}{
+		"Link":        h.cfg.App.URL + "/users/unlock?token=" + url.QueryEscape(tokenStr),
+		"LockedUntil": *user.LockedUntil,
+	})
+}
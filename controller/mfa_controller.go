@@ -0,0 +1,371 @@
+package controller
+
+import (
+	"crypto/rand"
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/loginguard"
+	"e_commerce/middleware"
+	"e_commerce/models"
+	"e_commerce/totp"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeSubject = "mfa-challenge"
+	mfaChallengeTTL     = 5 * time.Minute
+	recoveryCodeCount   = 10
+)
+
+// SetupTOTP godoc
+// @Summary Start 2FA enrollment
+// @Description Generate a new TOTP secret for the logged-in user. 2FA is enabled after a code from the authenticator app is confirmed.
+// @Tags 2FA
+// @Produce  json
+// @Success 200 {object} map[string]string "secret and otpauth provisioning URI"
+// @Failure 400 {string} string "Two-factor authentication is already enabled"
+// @Failure 500 {string} string "Failed to set up two-factor authentication"
+// @Router /users/2fa/setup [post]
+func (h *Handler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is already enabled.", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Failed to set up two-factor authentication.", http.StatusInternalServerError)
+		return
+	}
+
+	sealed, err := h.secrets.Seal(secret)
+	if err != nil {
+		http.Error(w, "Failed to set up two-factor authentication.", http.StatusInternalServerError)
+		return
+	}
+
+	if result := database.DB.Model(&user).Updates(map[string]interface{}{"totp_secret": sealed, "totp_last_step": 0}); result.Error != nil {
+		http.Error(w, "Failed to set up two-factor authentication.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(h.cfg.App.Name, user.Email, secret),
+	})
+}
+
+// EnableTOTP godoc
+// @Summary Confirm 2FA enrollment
+// @Description Enable 2FA with a code from the authenticator app and get the recovery codes. The recovery codes are shown only once.
+// @Tags 2FA
+// @Accept  json
+// @Produce  json
+// @Param   body body models.TOTPCodeRequest true "Code"
+// @Success 200 {object} map[string][]string "recovery_codes"
+// @Failure 400 {string} string "Invalid input" / "Start the setup first" / "Invalid code"
+// @Failure 500 {string} string "Failed to enable two-factor authentication"
+// @Router /users/2fa/enable [post]
+func (h *Handler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is already enabled.", http.StatusBadRequest)
+		return
+	}
+	if user.TOTPSecret == "" {
+		http.Error(w, "Start the setup first.", http.StatusBadRequest)
+		return
+	}
+	if !h.verifyTOTP(database.DB, user, input.Code) {
+		http.Error(w, "Invalid code.", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		after := user
+		after.TOTPEnabled = true
+		return audit.Record(tx, r, audit.Entry{Action: "user.2fa.enable", EntityType: "user", EntityID: user.ID, Before: user, After: after})
+	})
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// DisableTOTP godoc
+// @Summary Disable 2FA
+// @Description Disable 2FA for the logged-in user with the password and a current code. Not allowed for roles that require 2FA.
+// @Tags 2FA
+// @Accept  json
+// @Produce  json
+// @Param   body body models.TOTPDisableRequest true "Password and code"
+// @Success 200 {string} string "Two-factor authentication disabled"
+// @Failure 400 {string} string "Invalid input" / "Invalid code"
+// @Failure 401 {string} string "Password is incorrect"
+// @Failure 403 {string} string "Two-factor authentication is required for your role"
+// @Failure 500 {string} string "Failed to disable two-factor authentication"
+// @Router /users/2fa/disable [post]
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.TOTPDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if middleware.MFARequired(h.cfg.Features, user.Role) {
+		http.Error(w, "Two-factor authentication is required for your role.", http.StatusForbidden)
+		return
+	}
+	if !user.TOTPEnabled {
+		http.Error(w, "Two-factor authentication is not enabled.", http.StatusBadRequest)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		http.Error(w, "Password is incorrect.", http.StatusUnauthorized)
+		return
+	}
+	if !h.verifyTOTP(database.DB, user, input.Code) {
+		http.Error(w, "Invalid code.", http.StatusBadRequest)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		after := user
+		after.TOTPEnabled = false
+		return audit.Record(tx, r, audit.Entry{Action: "user.2fa.disable", EntityType: "user", EntityID: user.ID, Before: user, After: after})
+	})
+	if err != nil {
+		http.Error(w, "Failed to disable two-factor authentication.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled."})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace the recovery codes of the logged-in user after confirming a current code
+// @Tags 2FA
+// @Accept  json
+// @Produce  json
+// @Param   body body models.TOTPCodeRequest true "Code"
+// @Success 200 {object} map[string][]string "recovery_codes"
+// @Failure 400 {string} string "Invalid input" / "Invalid code"
+// @Failure 500 {string} string "Failed to generate recovery codes"
+// @Router /users/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if !user.TOTPEnabled || !h.verifyTOTP(database.DB, user, input.Code) {
+		http.Error(w, "Invalid code.", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.2fa.recovery_codes", EntityType: "user", EntityID: user.ID})
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// LoginMFA godoc
+// @Summary Complete a 2FA login
+// @Description Exchange the MFA challenge token from the login step and an authenticator or recovery code for a session token
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param   body body models.MFALoginRequest true "MFA Login Request"
+// @Success 200 {string} string "Logged in successfully"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Invalid or expired MFA token" / "Invalid code"
+// @Failure 429 {string} string "Too many failed login attempts" / "Account is temporarily locked"
+// @Failure 500 {string} string "Failed to create token"
+// @Router /users/login/mfa [post]
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var input models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	claims := &models.MFAChallengeClaims{}
+	token, err := jwt.ParseWithClaims(input.MFAToken, claims, func(t *jwt.Token) (interface{}, error) {
+		return h.tokenKey(mfaChallengeSubject), nil
+	})
+	if err != nil || !token.Valid || claims.Subject != mfaChallengeSubject {
+		http.Error(w, "Invalid or expired MFA token.", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil || !user.TOTPEnabled {
+		http.Error(w, "Invalid or expired MFA token.", http.StatusUnauthorized)
+		return
+	}
+
+	ip := h.clientIP(r)
+	policy := h.policy
+	if loginThrottled(w, policy, &user, user.Email, ip) {
+		return
+	}
+
+	valid := false
+	if input.RecoveryCode != "" {
+		valid = useRecoveryCode(database.DB, user.ID, input.RecoveryCode)
+	} else {
+		valid = h.verifyTOTP(database.DB, user, input.Code)
+	}
+	if !valid {
+		h.loginFailed(policy, &user, user.Email, ip, loginguard.ReasonInvalidMFACode)
+		http.Error(w, "Invalid code.", http.StatusUnauthorized)
+		return
+	}
+
+	loginSucceeded(&user, ip, loginguard.ReasonMFA)
+	h.issueLoginToken(w, user)
+}
+
+// issueMFAChallenge returns a short-lived token proving that the user passed the password step.
+func (h *Handler) issueMFAChallenge(user models.User) (string, error) {
+	claims := &models.MFAChallengeClaims{
+		UserID: user.ID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   mfaChallengeSubject,
+			ExpiresAt: time.Now().Add(mfaChallengeTTL).Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.tokenKey(mfaChallengeSubject))
+}
+
+// verifyTOTP checks a code and records its time step so the same code cannot be used twice.
+func (h *Handler) verifyTOTP(db *gorm.DB, user models.User, code string) bool {
+	secret, err := h.secrets.Open(user.TOTPSecret)
+	if err != nil {
+		log.Printf("Failed to decrypt the 2FA secret of user %d: %v", user.ID, err)
+		return false
+	}
+	step, ok := totp.Accept(secret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false
+	}
+
+	result := db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func useRecoveryCode(db *gorm.DB, userID uint, code string) bool {
+	hash := hashToken(normalizeRecoveryCode(code))
+	result := db.Model(&models.RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and returns new ones.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		h := hex.EncodeToString(b)
+		code := h[0:4] + "-" + h[4:8] + "-" + h[8:12]
+
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:    userID,
+			CodeHash:  hashToken(normalizeRecoveryCode(code)),
+			CreatedAt: time.Now(),
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
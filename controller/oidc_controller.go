@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"e_commerce/oidc"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcFlowSubject = "oidc-flow"
+	oidcFlowCookie  = "oidc_flow"
+	oidcFlowTTL     = 10 * time.Minute
+)
+
+var errUnverifiedIdentity = errors.New("identity has no verified email")
+
+// GetOIDCProviders godoc
+// @Summary List social login providers
+// @Description Get the names of the configured identity providers
+// @Tags User
+// @Produce  json
+// @Success 200 {array} string
+// @Router /auth/providers [get]
+func (h *Handler) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.providers.Names())
+}
+
+// OIDCLogin godoc
+// @Summary Start a social login
+// @Description Redirect to the identity provider. login_hint is passed on to the provider.
+// @Tags User
+// @Param   provider path string true "Provider name"
+// @Param   login_hint query string false "Email hint for the provider"
+// @Success 302 {string} string "Redirect to the provider"
+// @Failure 404 {string} string "Unknown provider"
+// @Failure 502 {string} string "Identity provider is not available"
+// @Router /auth/{provider}/login [get]
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := h.providers.Get(name)
+	if !ok {
+		http.Error(w, "Unknown provider.", http.StatusNotFound)
+		return
+	}
+
+	claims := &models.OIDCFlowClaims{
+		Provider: name,
+		State:    oidc.RandomString(),
+		Nonce:    oidc.RandomString(),
+		Verifier: oidc.RandomString(),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   oidcFlowSubject,
+			ExpiresAt: time.Now().Add(oidcFlowTTL).Unix(),
+		},
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), h.oidcRedirectURI(name), claims.State, claims.Nonce, claims.Verifier)
+	if err != nil {
+		log.Printf("OIDC provider %s: %v", name, err)
+		http.Error(w, "Identity provider is not available.", http.StatusBadGateway)
+		return
+	}
+	if hint := r.URL.Query().Get("login_hint"); hint != "" {
+		authURL += "&login_hint=" + url.QueryEscape(hint)
+	}
+
+	flow, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.tokenKey(oidcFlowSubject))
+	if err != nil {
+		http.Error(w, "Failed to create token.", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    flow,
+		Path:     "/auth/",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.cfg.App.URL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback godoc
+// @Summary Finish a social login
+// @Description Handle the redirect back from the identity provider. The account is linked to an existing user by verified email or a new customer account is created.
+// @Tags User
+// @Produce  json
+// @Param   provider path string true "Provider name"
+// @Param   code query string true "Authorization code"
+// @Param   state query string true "State"
+// @Success 200 {string} string "Logged in successfully, or an MFA challenge token when 2FA is enabled"
+// @Failure 400 {string} string "Invalid or expired login request" / "Login was cancelled or denied"
+// @Failure 403 {string} string "Your email address at the provider is not verified"
+// @Failure 502 {string} string "Failed to sign in with the identity provider"
+// @Router /auth/{provider}/callback [get]
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := h.providers.Get(name)
+	if !ok {
+		http.Error(w, "Unknown provider.", http.StatusNotFound)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		http.Error(w, "Invalid or expired login request.", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Value: "", Path: "/auth/", MaxAge: -1})
+
+	claims := &models.OIDCFlowClaims{}
+	token, err := jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
+		return h.tokenKey(oidcFlowSubject), nil
+	})
+	q := r.URL.Query()
+	if err != nil || !token.Valid || claims.Subject != oidcFlowSubject || claims.Provider != name || q.Get("state") != claims.State {
+		http.Error(w, "Invalid or expired login request.", http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("error") != "" || q.Get("code") == "" {
+		http.Error(w, "Login was cancelled or denied.", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), q.Get("code"), h.oidcRedirectURI(name), claims.Verifier, claims.Nonce)
+	if err != nil {
+		log.Printf("OIDC provider %s: %v", name, err)
+		http.Error(w, "Failed to sign in with the identity provider.", http.StatusBadGateway)
+		return
+	}
+
+	user, err := linkIdentity(r, name, identity)
+	if errors.Is(err, errUnverifiedIdentity) {
+		http.Error(w, "Your email address at the provider is not verified.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to sign in.", http.StatusInternalServerError)
+		return
+	}
+
+	if user.TOTPEnabled {
+		mfaToken, err := h.issueMFAChallenge(user)
+		if err != nil {
+			http.Error(w, "Failed to create token.", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	loginSucceeded(&user, h.clientIP(r), "oidc:"+name)
+	h.issueLoginToken(w, user)
+}
+
+// GetIdentities godoc
+// @Summary List linked social accounts
+// @Description Get the identity provider accounts linked to the logged-in user
+// @Tags User
+// @Produce  json
+// @Success 200 {array} models.UserIdentity
+// @Failure 500 {string} string "Failed to fetch identities"
+// @Router /users/profile/identities [get]
+func (h *Handler) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var identities []models.UserIdentity
+	if result := database.DB.Where("user_id = ?", claims.UserID).Order("created_at").Find(&identities); result.Error != nil {
+		http.Error(w, "Failed to fetch identities.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(identities)
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink a social account
+// @Description Remove the link between the logged-in user and an identity provider account
+// @Tags User
+// @Produce  json
+// @Param   provider path string true "Provider name"
+// @Success 200 {string} string "Identity unlinked"
+// @Failure 404 {string} string "Identity not found"
+// @Failure 500 {string} string "Failed to unlink identity"
+// @Router /users/profile/identities/{provider} [delete]
+func (h *Handler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var link models.UserIdentity
+	if result := database.DB.Where("user_id = ? AND provider = ?", claims.UserID, mux.Vars(r)["provider"]).First(&link); result.Error != nil {
+		http.Error(w, "Identity not found.", http.StatusNotFound)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&link).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user_identity.delete", EntityType: "user_identity", EntityID: link.ID, Before: link})
+	})
+	if err != nil {
+		http.Error(w, "Failed to unlink identity.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Identity unlinked."})
+}
+
+// linkIdentity returns the user of a provider account. An unknown account is linked to the user with
+// the same email when the provider has verified it, otherwise a new customer is created.
+func linkIdentity(r *http.Request, provider string, identity oidc.Identity) (models.User, error) {
+	var user models.User
+	created := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, identity.Subject).First(&link).Error
+		if err == nil {
+			return tx.First(&user, link.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		email, ok := identity.VerifiedEmail()
+		if !ok {
+			return errUnverifiedIdentity
+		}
+
+		err = tx.Where("email = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			if user.EmailVerifiedAt == nil {
+				// Someone who never proved ownership of the address may have registered it; drop their password and sessions.
+				password, err := unusablePassword()
+				if err != nil {
+					return err
+				}
+				before := user
+				now := time.Now()
+				user.EmailVerifiedAt = &now
+				user.Password = password
+				if err := tx.Model(&user).Updates(map[string]interface{}{"email_verified_at": now, "password": password}).Error; err != nil {
+					return err
+				}
+				if err := revokeSessions(tx, user.ID); err != nil {
+					return err
+				}
+				if err := audit.Record(tx, r, audit.Entry{Action: "user.verify", EntityType: "user", EntityID: user.ID, Before: before, After: user, Reason: provider}); err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			password, err := unusablePassword()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			name, surname := identity.GivenName, identity.FamilyName
+			if name == "" {
+				parts := strings.SplitN(strings.TrimSpace(identity.Name), " ", 2)
+				name = parts[0]
+				if len(parts) == 2 && surname == "" {
+					surname = parts[1]
+				}
+			}
+			user = models.User{
+				Name:            name,
+				Surname:         surname,
+				Email:           email,
+				Password:        password,
+				Role:            "customer",
+				Locale:          notification.DefaultLocale,
+				EmailVerifiedAt: &now,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(tx, r, audit.Entry{Action: "user.create", EntityType: "user", EntityID: user.ID, After: user, Reason: provider}); err != nil {
+				return err
+			}
+			created = true
+		default:
+			return err
+		}
+
+		link = models.UserIdentity{
+			UserID:    user.ID,
+			Provider:  provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user_identity.create", EntityType: "user_identity", EntityID: link.ID, After: link})
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if created {
+		notification.Notify(database.DB, user.ID, "welcome", nil)
+	}
+	return user, nil
+}
+
+// unusablePassword returns the hash of a random password for accounts created through a provider.
+// The user can set a real password with the password reset flow.
+func unusablePassword() (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(oidc.RandomString()), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func (h *Handler) oidcRedirectURI(provider string) string {
+	return h.cfg.App.URL + "/auth/" + provider + "/callback"
+}
@@ -1,16 +1,35 @@
 package controller
 
 import (
+	"e_commerce/audit"
 	"e_commerce/database"
+	"e_commerce/ledger"
 	"e_commerce/models"
+	"e_commerce/notification"
+	"e_commerce/rbac"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
+var errOutOfStock = errors.New("not available in the required quantity")
+
+var errOrderSettled = errors.New("order is settled")
+
+// orderTransitions lists the statuses a seller may move a shop order to from its current status.
+// Delivered orders are changed through returns and cancelled orders cannot be reopened.
+var orderTransitions = map[string][]string{
+	"pending":   {"confirmed", "shipped", "cancelled"},
+	"confirmed": {"shipped", "cancelled"},
+	"shipped":   {"delivered"},
+}
+
 // CreateOrder godoc
 // @Summary Create a new order
 // @Description Create a new order for a product with the specified quantity
@@ -19,13 +38,13 @@ import (
 // @Produce  json
 // @Param   Authorization header string true "Bearer token"
 // @Param   product_id path int true "Product ID"
-// @Param   body body models.OrderItem true "Order Item"
+// @Param   body body models.CreateOrderRequest true "Order Request"
 // @Success 200 {string} string "Order created successfully"
-// @Failure 400 {string} string "Invalid id" / "Invalid input" / "Not available in the required quantity"
+// @Failure 400 {string} string "Invalid id" / "Invalid input" / "Shipping address not found" / "Invalid shipping address" / "Shipping method not found" / "Not available in the required quantity"
 // @Failure 404 {string} string "Product not found"
 // @Failure 500 {string} string "Failed to create order" / "Failed to create order item" / "Failed to begin transaction" / "Failed to commit transaction"
 // @Router /orders/{product_id} [post]
-func CreateOrder(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("user").(*models.Claims)
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["product_id"])
@@ -35,77 +54,128 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var product models.Product
-	if result := database.DB.First(&product, productID); result.Error != nil {
+	if result := listedProducts(database.DB).First(&product, productID); result.Error != nil {
 		http.Error(w, "Product not found.", http.StatusNotFound)
 		return
 	}
 
-	var orderItem models.OrderItem
-	err = json.NewDecoder(r.Body).Decode(&orderItem)
-	if err != nil {
+	var input models.CreateOrderRequest
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Quantity <= 0 {
 		http.Error(w, "Invalid input.", http.StatusBadRequest)
 		return
 	}
-	orderItem.ProductID = product.ID
-	orderItem.CreatedAt = time.Now()
-	orderItem.UpdatedAt = time.Now()
-	orderItem.Price = product.Price
-	orderItem.Total = orderItem.Price * float64(orderItem.Quantity)
 
-	if product.Stock < orderItem.Quantity {
-		http.Error(w, "Not available in the required quantity.", http.StatusBadRequest)
-		return
+	checkout := models.CheckoutRequest{
+		Items:             []models.CheckoutItem{{ProductID: product.ID, Quantity: input.Quantity}},
+		ShippingAddressID: input.ShippingAddressID,
+		BillingAddressID:  input.BillingAddressID,
+		ShippingMethods:   map[uint]uint{product.ShopID: input.ShippingMethodID},
+		ShippingAddress:   input.ShippingAddress,
 	}
 
-	var order models.Order
-	order.UserID = claims.UserID
-	order.TotalAmount = orderItem.Total
-	order.Status = "pending"
-	order.CreatedAt = time.Now()
-	order.UpdatedAt = time.Now()
-
-	tx := database.DB.Begin()
-	if tx.Error != nil {
-		http.Error(w, "Failed to begin transaction."+tx.Error.Error(), http.StatusInternalServerError)
+	if _, ok := placeOrder(w, r, claims.UserID, checkout); !ok {
 		return
 	}
 
-	if result := tx.Create(&order); result.Error != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to create order."+result.Error.Error(), http.StatusInternalServerError)
-		return
-	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Order created successfully."})
+}
 
-	orderItem.OrderID = order.ID
-	if result := tx.Create(&orderItem); result.Error != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to create order item."+result.Error.Error(), http.StatusInternalServerError)
+// Checkout godoc
+// @Summary Checkout a cart
+// @Description Create an order for products from one or more shops. Each shop gets its own sub-order with its own status, shipment and shipping method.
+// @Tags Orders
+// @Accept  json
+// @Produce  json
+// @Param   body body models.CheckoutRequest true "Checkout Request"
+// @Success 201 {object} models.Order
+// @Failure 400 {string} string "Invalid input" / "Shipping address not found" / "Invalid shipping address" / "Shipping method is required" / "Not available in the required quantity"
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Failed to create order"
+// @Router /orders/checkout [post]
+func (h *Handler) Checkout(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.CheckoutRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
 		return
 	}
 
-	if tx.Commit().Error != nil {
-		http.Error(w, "Failed to commit transaction.", http.StatusInternalServerError)
+	order, ok := placeOrder(w, r, claims.UserID, input)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Order created successfully."})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
 }
 
 // UpdateOrderStatus godoc
 // @Summary Update the status of an order
-// @Description Updates the status of an order by its ID
+// @Description Updates the status of a shop order of the logged-in seller by its ID
 // @Tags Orders
 // @Accept  json
 // @Produce  json
 // @Param   order_id path int true "Order ID"
-// @Param   body body object true "Order Status Update"
+// @Param   body body models.OrderStatusRequest true "Order Status Update"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
 // @Success 200 {object} map[string]string "Order status updated successfully"
-// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 400 {string} string "Invalid id" / "Invalid input" / "Invalid status transition"
 // @Failure 404 {string} string "Order not found"
 // @Failure 500 {string} string "Failed to update order status"
 // @Router /orders/{order_id}/status [put]
-func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	order, _, ok := findSellerOrder(w, r, rbac.ShopFulfil)
+	if !ok {
+		return
+	}
+
+	var input models.OrderStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !validOrderStatus(input.Status) {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	if !canTransition(order.Status, input.Status) {
+		http.Error(w, "Invalid status transition.", http.StatusBadRequest)
+		return
+	}
+
+	before := order
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := h.applyOrderStatus(tx, &order, input.Status); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "order.status." + input.Status, EntityType: "order", EntityID: order.ID, Before: before, After: order})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update order status.", http.StatusInternalServerError)
+		return
+	}
+
+	notification.Notify(database.DB, order.UserID, "order_status", map[string]interface{}{"Order": order})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Order status update successfully."})
+
+}
+
+// GetOrder godoc
+// @Summary Get an order
+// @Description Get an order of the logged-in user with its shop sub-orders, items, returns and shipments
+// @Tags Orders
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Success 200 {object} models.Order
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Order not found"
+// @Router /orders/{order_id} [get]
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
 	params := mux.Vars(r)
 	orderID, err := strconv.Atoi(params["order_id"])
 	if err != nil {
@@ -113,34 +183,353 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var input struct {
-		Status string `json:"status"`
+	var order models.Order
+	if result := scopeOwnOrders(preloadOrder(database.DB), claims).First(&order, orderID); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&input)
-	if err != nil {
-		http.Error(w, "Invalid input.", http.StatusBadRequest)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(order)
+}
+
+// GetMyOrders godoc
+// @Summary Get my orders
+// @Description Get the orders of the logged-in user with their shop sub-orders
+// @Tags Orders
+// @Produce  json
+// @Success 200 {array} models.Order
+// @Failure 500 {string} string "Failed to retrieve orders"
+// @Router /orders [get]
+func (h *Handler) GetMyOrders(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var orders []models.Order
+	if result := preloadOrder(database.DB).Where("user_id = ? AND parent_id IS NULL", claims.UserID).Order("created_at DESC").Find(&orders); result.Error != nil {
+		http.Error(w, "Failed to retrieve orders.", http.StatusInternalServerError)
 		return
 	}
 
-	tx := database.DB.Begin()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(orders)
+}
 
-	var order models.Order
-	if result := tx.First(&order, orderID); result.Error != nil {
-		tx.Rollback()
-		http.Error(w, "Order not found.", http.StatusNotFound)
+// GetShopOrders godoc
+// @Summary Get orders of my shop
+// @Description Get the sub-orders placed at the logged-in seller's shop
+// @Tags Orders
+// @Produce  json
+// @Param   status query string false "Status"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.Order
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to retrieve orders"
+// @Router /shop/orders [get]
+func (h *Handler) GetShopOrders(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopView)
+	if !ok {
 		return
 	}
 
-	order.Status = input.Status
+	query := database.DB.Preload("Items").Preload("Shipments.Events").Where("shop_id = ?", shop.ID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
 
-	if result := tx.Save(&order); result.Error != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to update order status.", http.StatusInternalServerError)
+	var orders []models.Order
+	if result := query.Order("created_at DESC").Find(&orders); result.Error != nil {
+		http.Error(w, "Failed to retrieve orders.", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Order status update successfully."})
+	json.NewEncoder(w).Encode(orders)
+}
+
+// GetShopOrder godoc
+// @Summary Get an order of my shop
+// @Description Get a sub-order placed at the logged-in seller's shop with its items, returns and shipments
+// @Tags Orders
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.Order
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Order not found"
+// @Router /shop/orders/{order_id} [get]
+func (h *Handler) GetShopOrder(w http.ResponseWriter, r *http.Request) {
+	order, _, ok := findSellerOrder(w, r, rbac.ShopView)
+	if !ok {
+		return
+	}
+
+	database.DB.Preload("Items").Preload("Returns.Items").Preload("Shipments.Events").First(&order, order.ID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(order)
+}
+
+// placeOrder creates a parent order for the customer and one sub-order per shop of the requested items.
+func placeOrder(w http.ResponseWriter, r *http.Request, userID uint, input models.CheckoutRequest) (models.Order, bool) {
+	var order models.Order
+	if len(input.Items) == 0 {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return order, false
+	}
+
+	var shippingAddress models.Address
+	var err error
+	if input.ShippingAddressID == 0 && input.ShippingAddress != nil {
+		// Adres defteri boş olan kullanıcılar adresi siparişle birlikte gönderebilir
+		shippingAddress = *input.ShippingAddress
+		if !validOrderAddress(shippingAddress) {
+			http.Error(w, "Invalid shipping address.", http.StatusBadRequest)
+			return order, false
+		}
+	} else if shippingAddress, err = resolveOrderAddress(userID, input.ShippingAddressID, "is_default_shipping"); err != nil {
+		http.Error(w, "Shipping address not found.", http.StatusBadRequest)
+		return order, false
+	}
+
+	billingAddress := shippingAddress
+	if input.BillingAddressID != 0 {
+		billingAddress, err = resolveOrderAddress(userID, input.BillingAddressID, "is_default_billing")
+		if err != nil {
+			http.Error(w, "Billing address not found.", http.StatusBadRequest)
+			return order, false
+		}
+	}
+
+	now := time.Now()
+	order = models.Order{
+		UserID:          userID,
+		Status:          "pending",
+		ShippingAddress: shippingAddress.Snapshot(),
+		BillingAddress:  billingAddress.Snapshot(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	var shopIDs []uint
+	subOrders := make(map[uint]*models.Order)
+	weights := make(map[uint]float64)
+	for _, item := range input.Items {
+		if item.Quantity <= 0 {
+			http.Error(w, "Invalid input.", http.StatusBadRequest)
+			return order, false
+		}
+
+		var product models.Product
+		if result := listedProducts(database.DB).First(&product, item.ProductID); result.Error != nil {
+			http.Error(w, "Product not found.", http.StatusNotFound)
+			return order, false
+		}
+
+		if product.Stock < item.Quantity {
+			http.Error(w, "Not available in the required quantity.", http.StatusBadRequest)
+			return order, false
+		}
+
+		subOrder, exists := subOrders[product.ShopID]
+		if !exists {
+			shopID := product.ShopID
+			subOrder = &models.Order{
+				UserID:          userID,
+				ShopID:          &shopID,
+				Status:          "pending",
+				ShippingAddress: order.ShippingAddress,
+				BillingAddress:  order.BillingAddress,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			}
+			subOrders[shopID] = subOrder
+			shopIDs = append(shopIDs, shopID)
+		}
+
+		orderItem := models.OrderItem{
+			ProductID: product.ID,
+			Quantity:  item.Quantity,
+			Price:     product.Price,
+			Total:     product.Price * float64(item.Quantity),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		subOrder.Items = append(subOrder.Items, orderItem)
+		subOrder.TotalAmount += orderItem.Total
+		weights[product.ShopID] += product.Weight * float64(item.Quantity)
+	}
+
+	for _, shopID := range shopIDs {
+		subOrder := subOrders[shopID]
+		shippingCost, shippingMethodID, ok := orderShipping(w, shopID, input.ShippingMethods[shopID], weights[shopID], subOrder.TotalAmount)
+		if !ok {
+			return order, false
+		}
+		subOrder.ShippingMethodID = shippingMethodID
+		subOrder.ShippingCost = shippingCost
+		subOrder.TotalAmount += shippingCost
+
+		order.ShippingCost += subOrder.ShippingCost
+		order.TotalAmount += subOrder.TotalAmount
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		for _, shopID := range shopIDs {
+			subOrder := subOrders[shopID]
+			subOrder.ParentID = &order.ID
+			if err := tx.Create(subOrder).Error; err != nil {
+				return err
+			}
+
+			for _, item := range subOrder.Items {
+				result := tx.Model(&models.Product{}).Where("id = ? AND stock >= ?", item.ProductID, item.Quantity).
+					Update("stock", gorm.Expr("stock - ?", item.Quantity))
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return errOutOfStock
+				}
+			}
+
+			order.SubOrders = append(order.SubOrders, *subOrder)
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "order.create", EntityType: "order", EntityID: order.ID, After: order})
+	})
+	if errors.Is(err, errOutOfStock) {
+		http.Error(w, "Not available in the required quantity.", http.StatusBadRequest)
+		return order, false
+	}
+	if err != nil {
+		log.Printf("Failed to create order for user %d: %v", userID, err)
+		http.Error(w, "Failed to create order.", http.StatusInternalServerError)
+		return order, false
+	}
+
+	notification.Notify(database.DB, userID, "order_confirmation", map[string]interface{}{"Order": order})
+
+	return order, true
+}
+
+// applyOrderStatus saves the status of an order, updates its parent order, puts the items back in stock when an
+// open order is cancelled and records the sale when it is delivered.
+func (h *Handler) applyOrderStatus(tx *gorm.DB, order *models.Order, status string) error {
+	previous := order.Status
+	order.Status = status
+	order.UpdatedAt = time.Now()
+
+	if err := tx.Omit("SubOrders", "Items", "Returns", "Shipments").Save(order).Error; err != nil {
+		return err
+	}
+	if err := syncParentOrder(tx, *order); err != nil {
+		return err
+	}
+	if status == "cancelled" && (previous == "pending" || previous == "confirmed" || previous == "shipped") {
+		if err := restockOrder(tx, order.ID); err != nil {
+			return err
+		}
+	}
+	if order.Status == "delivered" {
+		return ledger.RecordSale(tx, *order, h.cfg.Commerce.PlatformCommissionRate)
+	}
+	return nil
+}
+
+// settledOrder reports whether the order's sale has been recorded in the ledger, after which its status
+// only changes through returns.
+func settledOrder(status string) bool {
+	return status == "delivered" || status == "refunded"
+}
+
+// restockOrder puts the items of an order back in stock.
+func restockOrder(tx *gorm.DB, orderID uint) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := tx.Unscoped().Model(&models.Product{}).Where("id = ?", item.ProductID).
+			Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// syncParentOrder updates the status and refunded amount of the parent order from its shop sub-orders.
+// Cancelled sub-orders do not hold the parent back: it takes the status of the others, and once all of them
+// are delivered or refunded the parent is delivered.
+func syncParentOrder(tx *gorm.DB, order models.Order) error {
+	if order.ParentID == nil {
+		return nil
+	}
+
+	var subOrders []models.Order
+	if err := tx.Where("parent_id = ?", *order.ParentID).Find(&subOrders).Error; err != nil {
+		return err
+	}
+
+	status := ""
+	refunded := 0.0
+	finished := true
+	for _, subOrder := range subOrders {
+		refunded += subOrder.RefundedAmount
+		if subOrder.Status == "cancelled" {
+			continue
+		}
+		if subOrder.Status != "delivered" && subOrder.Status != "refunded" {
+			finished = false
+		}
+		if status == "" {
+			status = subOrder.Status
+		} else if status != subOrder.Status {
+			status = "processing"
+		}
+	}
+	switch {
+	case status == "":
+		status = "cancelled"
+	case status == "processing" && finished:
+		status = "delivered"
+	}
+
+	return tx.Model(&models.Order{}).Where("id = ?", *order.ParentID).Updates(map[string]interface{}{
+		"status":          status,
+		"refunded_amount": refunded,
+		"updated_at":      time.Now(),
+	}).Error
+}
+
+func preloadOrder(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items").Preload("Returns.Items").Preload("Shipments.Events").
+		Preload("SubOrders.Items").Preload("SubOrders.Returns.Items").Preload("SubOrders.Shipments.Events")
+}
+
+// scopeOwnOrders limits a query to the user's own orders unless they may read any order.
+func scopeOwnOrders(db *gorm.DB, claims *models.Claims) *gorm.DB {
+	if rbac.Has(database.DB, claims.Role, rbac.OrdersReadAny) {
+		return db
+	}
+	return db.Where("user_id = ?", claims.UserID)
+}
+
+func canTransition(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+func validOrderStatus(status string) bool {
+	switch status {
+	case "pending", "confirmed", "shipped", "delivered", "cancelled":
+		return true
+	}
+	return false
 }
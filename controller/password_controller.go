@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/loginguard"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const minPasswordLength = 8
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Send a single-use password reset link to the email address. The response and its timing are the same whether
+// @Description the address exists or not. The link opens the frontend page configured as the reset password path.
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param   body body models.ForgotPasswordRequest true "Email"
+// @Success 200 {string} string "If the email is registered, a reset link has been sent"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 429 {string} string "Too many password reset requests"
+// @Failure 500 {string} string "Failed to request password reset"
+// @Router /users/password/forgot [post]
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input models.ForgotPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Email == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	ip := h.clientIP(r)
+	now := time.Now()
+	since := now.Add(-h.cfg.Auth.PasswordResetWindow)
+
+	var byEmail, byIP int64
+	if err := database.DB.Model(&models.PasswordResetRequest{}).Where("email = ? AND created_at > ?", input.Email, since).Count(&byEmail).Error; err != nil {
+		http.Error(w, "Failed to request password reset.", http.StatusInternalServerError)
+		return
+	}
+	if err := database.DB.Model(&models.PasswordResetRequest{}).Where("ip = ? AND created_at > ?", ip, since).Count(&byIP).Error; err != nil {
+		http.Error(w, "Failed to request password reset.", http.StatusInternalServerError)
+		return
+	}
+	if int(byEmail) >= h.cfg.Auth.PasswordResetLimit || int(byIP) >= h.cfg.Auth.PasswordResetIPLimit {
+		http.Error(w, "Too many password reset requests.", http.StatusTooManyRequests)
+		return
+	}
+
+	if err := database.DB.Create(&models.PasswordResetRequest{Email: input.Email, IP: ip, CreatedAt: now}).Error; err != nil {
+		http.Error(w, "Failed to request password reset.", http.StatusInternalServerError)
+		return
+	}
+
+	// Hesap arka planda aranır, yanıt süresi e-postanın kayıtlı olup olmadığını belli etmez
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		database.DB.Where("created_at < ?", since).Delete(&models.PasswordResetRequest{})
+
+		var user models.User
+		if result := database.DB.Where("email = ?", input.Email).First(&user); result.Error != nil {
+			return
+		}
+		if err := h.sendPasswordReset(user); err != nil {
+			log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If the email is registered, a reset link has been sent."})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with a reset token and sign out all existing sessions
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param   body body models.ResetPasswordRequest true "Reset Request"
+// @Success 200 {string} string "Password reset successfully"
+// @Failure 400 {string} string "Invalid input" / "Invalid or expired reset token"
+// @Failure 500 {string} string "Failed to reset password"
+// @Router /users/password/reset [post]
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input models.ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Token == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	if len(input.NewPassword) < minPasswordLength {
+		http.Error(w, "Password must be at least 8 characters.", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password.", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(input.Token), now).First(&reset).Error; err != nil {
+			return errInvalidResetToken
+		}
+
+		result := tx.Model(&reset).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", reset.UserID).
+			Updates(map[string]interface{}{"password": string(hashedPassword), "updated_at": now}).Error; err != nil {
+			return err
+		}
+
+		if err := loginguard.Unlock(tx, reset.UserID); err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, reset.UserID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{ActorID: reset.UserID, Action: "user.password.reset", EntityType: "user", EntityID: reset.UserID})
+	})
+	if err == errInvalidResetToken {
+		http.Error(w, "Invalid or expired reset token.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset successfully."})
+}
+
+// sendPasswordReset replaces the user's unused reset tokens with a new one and emails it.
+func (h *Handler) sendPasswordReset(user models.User) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now()
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(h.cfg.Auth.PasswordResetTTL),
+		CreatedAt: now,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&reset).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return notification.Enqueue(database.DB, user.ID, "password_reset", map[string]interface{}{
+		"Link":      h.cfg.App.URL + h.cfg.App.ResetPasswordPath + "?token=" + url.QueryEscape(token),
+		"ExpiresAt": reset.ExpiresAt,
+	})
+}
+
+// revokeSessions invalidates every login token and refresh token issued to the user so far.
+func revokeSessions(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("tokens_revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RefreshToken{}).Error
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"bytes"
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/privacy"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ExportPersonalData godoc
+// @Summary Export my personal data
+// @Description Download the profile, addresses, orders, reviews and the rest of the data stored about the logged-in user as a ZIP archive of JSON files, or as one JSON document with format=json
+// @Tags User
+// @Produce  application/zip
+// @Produce  json
+// @Param   format query string false "zip (default) or json"
+// @Success 200 {object} models.PersonalDataExport
+// @Failure 400 {string} string "Unsupported format"
+// @Failure 500 {string} string "Failed to export personal data"
+// @Router /users/profile/export [get]
+func (h *Handler) ExportPersonalData(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "zip" && format != "json" {
+		http.Error(w, "Unsupported format.", http.StatusBadRequest)
+		return
+	}
+
+	data, err := privacy.Export(database.DB, claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to export personal data.", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("personal-data-%d-%s", claims.UserID, data.ExportedAt.Format("20060102"))
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(data)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := privacy.WriteArchive(&buf, data); err != nil {
+		http.Error(w, "Failed to export personal data.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// ErasePersonalData godoc
+// @Summary Erase my personal data
+// @Description Anonymize the logged-in user and close the account. Orders, billing addresses and invoices are kept for accounting.
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param   request body models.ErasureRequest true "Password confirmation"
+// @Success 200 {string} string "Personal data erased"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Password is incorrect"
+// @Failure 409 {string} string "Orders in progress or shop not closed"
+// @Failure 500 {string} string "Failed to erase personal data"
+// @Router /users/profile/erase [post]
+func (h *Handler) ErasePersonalData(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		http.Error(w, "Password is incorrect.", http.StatusUnauthorized)
+		return
+	}
+
+	eraseUser(w, r, user)
+}
+
+// AdminEraseUser godoc
+// @Summary Erase a user's personal data
+// @Description Anonymize a user and close the account, for erasure requests received outside the app
+// @Tags Admin
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Success 200 {string} string "Personal data erased"
+// @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "Orders in progress or shop not closed"
+// @Failure 500 {string} string "Failed to erase personal data"
+// @Router /admin/users/{user_id}/erase [post]
+func (h *Handler) AdminEraseUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if result := database.DB.First(&user, mux.Vars(r)["user_id"]); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	eraseUser(w, r, user)
+}
+
+func eraseUser(w http.ResponseWriter, r *http.Request, user models.User) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := privacy.CheckErasable(tx, user.ID); err != nil {
+			return err
+		}
+		if err := privacy.Erase(tx, &user, time.Now()); err != nil {
+			return err
+		}
+		// Kayda kişisel veri yazılmaz, yalnızca silme işlemi iz bırakır
+		return audit.Record(tx, r, audit.Entry{Action: "user.erase", EntityType: "user", EntityID: user.ID})
+	})
+	switch {
+	case errors.Is(err, privacy.ErrOpenOrders):
+		http.Error(w, "Personal data cannot be erased while orders or returns are in progress.", http.StatusConflict)
+		return
+	case errors.Is(err, privacy.ErrShopOwner):
+		http.Error(w, "Close or transfer your shops before erasing personal data.", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to erase personal data.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Personal data erased."})
+}
@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"e_commerce/audit"
 	"e_commerce/database"
 	"e_commerce/models"
+	"e_commerce/rbac"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 // AddProduct godoc
@@ -19,14 +22,13 @@ import (
 // @Accept json
 // @Produce json
 // @Param product body models.Product true "Product details"
+// @Param X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
 // @Success 201 {object} models.Product
 // @Failure 400 {string} string "Invalid input"
 // @Failure 404 {string} string "Shop not found"
 // @Failure 500 {string} string "Failed to create product"
 // @Router /product [post]
-func AddProduct(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("user").(*models.Claims)
-
+func (h *Handler) AddProduct(w http.ResponseWriter, r *http.Request) {
 	var product models.Product
 	err := json.NewDecoder(r.Body).Decode(&product)
 	if err != nil {
@@ -34,17 +36,24 @@ func AddProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var shop models.Shop
-	if result := database.DB.Where("owner_id = ?", claims.UserID).First(&shop); result.Error != nil {
-		http.Error(w, "Shop not found.", http.StatusNotFound)
+	shop, ok := findMyShop(w, r, rbac.ShopProducts)
+	if !ok {
 		return
 	}
 
 	product.ShopID = shop.ID
+	product.RatingAvg = 0
+	product.RatingCount = 0
 	product.CreatedAt = time.Now()
 	product.UpdatedAt = time.Now()
 
-	if result := database.DB.Create(&product); result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "product.create", EntityType: "product", EntityID: product.ID, After: product})
+	})
+	if err != nil {
 		http.Error(w, "Failed to create product.", http.StatusInternalServerError)
 		return
 	}
@@ -61,12 +70,13 @@ func AddProduct(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param product_id path int true "Product ID"
 // @Param product body models.Product true "Updated product details"
+// @Param X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
 // @Success 200 {string} string "Product updated successfully."
 // @Failure 400 {string} string "Invalid id or input"
 // @Failure 404 {string} string "Product not found"
 // @Failure 500 {string} string "Failed to update product"
 // @Router /product/{product_id} [put]
-func UpdateProduct(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["product_id"])
 	if err != nil {
@@ -81,24 +91,43 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	shop, ok := findMyShop(w, r, rbac.ShopProducts)
+	if !ok {
+		return
+	}
+
 	var product models.Product
-	if result := database.DB.First(&product, productID); result.Error != nil {
+	if result := database.DB.Where("shop_id = ?", shop.ID).First(&product, productID); result.Error != nil {
 		http.Error(w, "Product not found.", http.StatusNotFound)
 		return
 	}
 
+	before := product
+	oldPrice, oldStock := product.Price, product.Stock
 	product.Stock = input.Stock
 	product.Price = input.Price
 	product.UpdatedAt = time.Now()
 	product.ImageUrl = input.ImageUrl
 	product.Description = input.Description
 	product.Name = input.Name
-
-	if result := database.DB.Save(&product); result.Error != nil {
+	product.Weight = input.Weight
+	product.Length = input.Length
+	product.Width = input.Width
+	product.Height = input.Height
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&product).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "product.update", EntityType: "product", EntityID: product.ID, Before: before, After: product})
+	})
+	if err != nil {
 		http.Error(w, "Failed to update product.", http.StatusInternalServerError)
 		return
 	}
 
+	notifyWishlistOwners(product, oldPrice, oldStock)
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Product updated successfully."})
 
@@ -114,7 +143,7 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {string} string "Invalid id"
 // @Failure 404 {string} string "Product not found"
 // @Router /product/{product_id} [get]
-func GetProduct(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	productID, err := strconv.Atoi(params["product_id"])
 	if err != nil {
@@ -123,7 +152,7 @@ func GetProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var product models.Product
-	if result := database.DB.First(&product, productID); result.Error != nil {
+	if result := listedProducts(database.DB).First(&product, productID); result.Error != nil {
 		http.Error(w, "Product not found.", http.StatusNotFound)
 		return
 	}
@@ -140,9 +169,9 @@ func GetProduct(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {array} models.Product
 // @Failure 404 {string} string "Products not found"
 // @Router /product [get]
-func GetProducts(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
 	var products []models.Product
-	if result := database.DB.Find(&products); result.Error != nil {
+	if result := listedProducts(database.DB).Find(&products); result.Error != nil {
 		http.Error(w, "Products not found.", http.StatusNotFound)
 		return
 	}
@@ -161,7 +190,7 @@ func GetProducts(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {string} string "Invalid id"
 // @Failure 404 {string} string "Products not
 // @Router /product/{shop_id}/products [get]
-func GetProductsByShop(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProductsByShop(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	shopID, err := strconv.Atoi(params["shop_id"])
 	if err != nil {
@@ -170,7 +199,7 @@ func GetProductsByShop(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var products []models.Product
-	if result := database.DB.Where("shop_id = ?", shopID).Find(&products); result.Error != nil {
+	if result := listedProducts(database.DB).Where("shop_id = ?", shopID).Find(&products); result.Error != nil {
 		http.Error(w, "Products not found.", http.StatusNotFound)
 		return
 	}
@@ -184,16 +213,14 @@ func GetProductsByShop(w http.ResponseWriter, r *http.Request) {
 // @Description Get the details of all products for the logged-in user's shop
 // @Tags Products
 // @Produce json
+// @Param X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
 // @Success 200 {array} models.Product
 // @Failure 404 {string} string "Shop or products not found"
 // @Router /product/my-products [get]
-func GetProductsByMyShop(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProductsByMyShop(w http.ResponseWriter, r *http.Request) {
 	log.Println("fonksiyon çalıştı.")
-	claims := r.Context().Value("user").(*models.Claims)
-
-	var shop models.Shop
-	if result := database.DB.Where("owner_id = ?", claims.UserID).First(&shop); result.Error != nil {
-		http.Error(w, "Shop not found.", http.StatusInternalServerError)
+	shop, ok := findMyShop(w, r, rbac.ShopView)
+	if !ok {
 		return
 	}
 
@@ -206,3 +233,9 @@ func GetProductsByMyShop(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(products)
 }
+
+// listedProducts limits a product query to the products customers can see: published products of active, approved shops.
+func listedProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("products.published = ? AND products.shop_id IN (?)", true,
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.Shop{}).Select("id").Where("status = ? AND approval = ?", "active", "approved"))
+}
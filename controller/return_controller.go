@@ -0,0 +1,414 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/ledger"
+	"e_commerce/models"
+	"e_commerce/payment"
+	"e_commerce/rbac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errReturnProcessed = errors.New("return already processed")
+
+var errReturnQuantity = errors.New("return quantity exceeds ordered quantity")
+
+// CreateReturn godoc
+// @Summary Request a return
+// @Description Request a return for some items of a delivered shop order. Quantities given for the same order item are added up.
+// @Tags Returns
+// @Accept  json
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Param   body body models.CreateReturnRequest true "Return Request"
+// @Success 201 {object} models.ReturnRequest
+// @Failure 400 {string} string "Invalid id" / "Invalid input" / "Order is not delivered" / "Invalid return item" / "Return quantity exceeds ordered quantity"
+// @Failure 404 {string} string "Order not found"
+// @Failure 500 {string} string "Failed to create return"
+// @Router /orders/{order_id}/returns [post]
+func (h *Handler) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	orderID, err := strconv.Atoi(params["order_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var input models.CreateReturnRequest
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Reason == "" || len(input.Items) == 0 {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var order models.Order
+	if result := database.DB.Preload("Items").Where("user_id = ?", claims.UserID).First(&order, orderID); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
+	}
+
+	if order.ShopID == nil {
+		http.Error(w, "Returns must be requested for a shop order.", http.StatusBadRequest)
+		return
+	}
+
+	if order.Status != "delivered" {
+		http.Error(w, "Only delivered orders can be returned.", http.StatusBadRequest)
+		return
+	}
+
+	orderItems := make(map[uint]models.OrderItem)
+	for _, item := range order.Items {
+		orderItems[item.ID] = item
+	}
+
+	ret := models.ReturnRequest{
+		OrderID:   order.ID,
+		UserID:    claims.UserID,
+		ShopID:    *order.ShopID,
+		Reason:    input.Reason,
+		Status:    "requested",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	// Bir sipariş kalemi birden fazla kez gönderilirse miktarlar toplanır
+	quantities := make(map[uint]int)
+	var itemIDs []uint
+	for _, in := range input.Items {
+		if _, ok := orderItems[in.OrderItemID]; !ok || in.Quantity <= 0 {
+			http.Error(w, "Invalid return item.", http.StatusBadRequest)
+			return
+		}
+		if _, seen := quantities[in.OrderItemID]; !seen {
+			itemIDs = append(itemIDs, in.OrderItemID)
+		}
+		quantities[in.OrderItemID] += in.Quantity
+	}
+
+	for _, id := range itemIDs {
+		orderItem, quantity := orderItems[id], quantities[id]
+		if quantity > orderItem.Quantity {
+			http.Error(w, "Return quantity exceeds ordered quantity.", http.StatusBadRequest)
+			return
+		}
+
+		amount := orderItem.Price * float64(quantity)
+		ret.RefundAmount += amount
+		ret.Items = append(ret.Items, models.ReturnItem{
+			OrderItemID: orderItem.ID,
+			ProductID:   orderItem.ProductID,
+			Quantity:    quantity,
+			Amount:      amount,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// The order row is locked so that concurrent returns of the same order count each other's items.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Order{}, order.ID).Error; err != nil {
+			return err
+		}
+		for _, item := range ret.Items {
+			var alreadyReturned int64
+			if err := tx.Model(&models.ReturnItem{}).
+				Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+				Where("return_items.order_item_id = ? AND return_requests.status <> ?", item.OrderItemID, "rejected").
+				Select("COALESCE(SUM(return_items.quantity), 0)").Scan(&alreadyReturned).Error; err != nil {
+				return err
+			}
+			if int(alreadyReturned)+item.Quantity > orderItems[item.OrderItemID].Quantity {
+				return errReturnQuantity
+			}
+		}
+
+		if err := tx.Create(&ret).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "return.create", EntityType: "return", EntityID: ret.ID, After: ret})
+	})
+	if err == errReturnQuantity {
+		http.Error(w, "Return quantity exceeds ordered quantity.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create return.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ret)
+}
+
+// GetOrderReturns godoc
+// @Summary Get returns of an order
+// @Description Get the return requests of an order of the logged-in user
+// @Tags Returns
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Success 200 {array} models.ReturnRequest
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Order not found"
+// @Router /orders/{order_id}/returns [get]
+func (h *Handler) GetOrderReturns(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	orderID, err := strconv.Atoi(params["order_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var order models.Order
+	if result := scopeOwnOrders(database.DB, claims).First(&order, orderID); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
+	}
+
+	var returns []models.ReturnRequest
+	if result := database.DB.Preload("Items").Where("order_id = ?", order.ID).Find(&returns); result.Error != nil {
+		http.Error(w, "Failed to retrieve returns.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(returns)
+}
+
+// GetShopReturns godoc
+// @Summary Get returns of my shop
+// @Description Get the return requests waiting for the logged-in seller's shop
+// @Tags Returns
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.ReturnRequest
+// @Failure 404 {string} string "Shop not found"
+// @Router /shop/returns [get]
+func (h *Handler) GetShopReturns(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopView)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Preload("Items").Where("shop_id = ?", shop.ID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var returns []models.ReturnRequest
+	if result := query.Find(&returns); result.Error != nil {
+		http.Error(w, "Failed to retrieve returns.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(returns)
+}
+
+// ApproveReturn godoc
+// @Summary Approve a return
+// @Description Approve a return request, restock the items and refund the customer. The approval is stored before the
+// @Description payment provider is called; when the refund fails the return stays approved and approving it again retries the refund.
+// @Tags Returns
+// @Accept  json
+// @Produce  json
+// @Param   return_id path int true "Return ID"
+// @Param   body body models.ReturnDecisionRequest false "Decision"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.ReturnRequest
+// @Failure 400 {string} string "Invalid id" / "Return already processed"
+// @Failure 404 {string} string "Return not found"
+// @Failure 500 {string} string "Failed to approve return"
+// @Failure 502 {string} string "Failed to refund"
+// @Router /returns/{return_id}/approve [put]
+func (h *Handler) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	ret, ok := findShopReturn(w, r, "requested", "approved")
+	if !ok {
+		return
+	}
+
+	var input models.ReturnDecisionRequest
+	json.NewDecoder(r.Body).Decode(&input)
+
+	if ret.Status == "requested" {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			before := ret
+			ret.Status = "approved"
+			ret.SellerNote = input.Note
+			ret.RefundKey = fmt.Sprintf("return-%d", ret.ID)
+			ret.UpdatedAt = time.Now()
+
+			// Koşullu güncelleme, aynı iadenin iki kez onaylanıp stoğun iki kez artmasını engeller
+			result := tx.Model(&models.ReturnRequest{}).Where("id = ? AND status = ?", ret.ID, "requested").
+				Updates(map[string]interface{}{"status": ret.Status, "seller_note": ret.SellerNote, "refund_key": ret.RefundKey, "updated_at": ret.UpdatedAt})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errReturnProcessed
+			}
+
+			for _, item := range ret.Items {
+				if err := tx.Unscoped().Model(&models.Product{}).Where("id = ?", item.ProductID).
+					Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+					return err
+				}
+			}
+
+			return audit.Record(tx, r, audit.Entry{Action: "return.approve", EntityType: "return", EntityID: ret.ID, Before: before, After: ret})
+		})
+		if errors.Is(err, errReturnProcessed) {
+			http.Error(w, "Return already processed.", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to approve return.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Sağlayıcı aynı anahtarla gelen tekrar isteğini yeni bir ödeme olarak işlemez
+	ref, err := payment.Default.Refund(ret.RefundKey, ret.OrderID, ret.RefundAmount)
+	if err != nil {
+		http.Error(w, "Failed to refund: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ret, ret.ID).Error; err != nil {
+			return err
+		}
+		if ret.Status != "approved" {
+			return errReturnProcessed
+		}
+
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, ret.OrderID).Error; err != nil {
+			return err
+		}
+		order.RefundedAmount += ret.RefundAmount
+		if order.RefundedAmount >= order.TotalAmount-order.ShippingCost {
+			order.Status = "refunded"
+		}
+		order.UpdatedAt = time.Now()
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		if err := syncParentOrder(tx, order); err != nil {
+			return err
+		}
+
+		before := ret
+		ret.Status = "refunded"
+		ret.RefundReference = ref
+		ret.UpdatedAt = time.Now()
+		if err := tx.Omit("Items").Save(&ret).Error; err != nil {
+			return err
+		}
+		if err := ledger.RecordRefund(tx, order, ret, h.cfg.Commerce.PlatformCommissionRate); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "return.refund", EntityType: "return", EntityID: ret.ID, Before: before, After: ret})
+	})
+	if errors.Is(err, errReturnProcessed) {
+		http.Error(w, "Return already processed.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to record refund.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ret)
+}
+
+// RejectReturn godoc
+// @Summary Reject a return
+// @Description Reject a return request with a reason
+// @Tags Returns
+// @Accept  json
+// @Produce  json
+// @Param   return_id path int true "Return ID"
+// @Param   body body models.ReturnDecisionRequest true "Decision"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.ReturnRequest
+// @Failure 400 {string} string "Invalid id" / "Invalid input" / "Return already processed"
+// @Failure 404 {string} string "Return not found"
+// @Failure 500 {string} string "Failed to reject return"
+// @Router /returns/{return_id}/reject [put]
+func (h *Handler) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	ret, ok := findShopReturn(w, r, "requested")
+	if !ok {
+		return
+	}
+
+	var input models.ReturnDecisionRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Note == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	before := ret
+	ret.Status = "rejected"
+	ret.SellerNote = input.Note
+	ret.UpdatedAt = time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Save(&ret).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "return.reject", EntityType: "return", EntityID: ret.ID, Before: before, After: ret, Reason: input.Note})
+	})
+	if err != nil {
+		http.Error(w, "Failed to reject return.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ret)
+}
+
+// findShopReturn loads a return request of the logged-in user's shop in one of the given statuses if their shop role
+// may decide returns.
+func findShopReturn(w http.ResponseWriter, r *http.Request, statuses ...string) (models.ReturnRequest, bool) {
+	params := mux.Vars(r)
+
+	var ret models.ReturnRequest
+	returnID, err := strconv.Atoi(params["return_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return ret, false
+	}
+
+	shop, ok := findMyShop(w, r, rbac.ShopFulfil)
+	if !ok {
+		return ret, false
+	}
+
+	if result := database.DB.Preload("Items").Where("shop_id = ?", shop.ID).First(&ret, returnID); result.Error != nil {
+		http.Error(w, "Return not found.", http.StatusNotFound)
+		return ret, false
+	}
+
+	for _, status := range statuses {
+		if ret.Status == status {
+			return ret, true
+		}
+	}
+	http.Error(w, "Return already processed.", http.StatusBadRequest)
+	return ret, false
+}
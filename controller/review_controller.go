@@ -0,0 +1,341 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/rbac"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// CreateReview godoc
+// @Summary Review a product
+// @Description Review a product the logged-in user bought and received
+// @Tags Reviews
+// @Accept  json
+// @Produce  json
+// @Param   product_id path int true "Product ID"
+// @Param   review body models.CreateReviewRequest true "Review"
+// @Success 201 {object} models.Review
+// @Failure 400 {string} string "Invalid id" / "Invalid input" / "You already reviewed this product"
+// @Failure 403 {string} string "Only buyers who received the product can review it"
+// @Failure 500 {string} string "Failed to create review"
+// @Router /product/{product_id}/reviews [post]
+func (h *Handler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	productID, err := strconv.Atoi(params["product_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var input models.CreateReviewRequest
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Rating < 1 || input.Rating > 5 || input.Title == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var orderItem models.OrderItem
+	if result := database.DB.Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status = ? AND order_items.product_id = ?", claims.UserID, "delivered", productID).
+		First(&orderItem); result.Error != nil {
+		http.Error(w, "Only buyers who received the product can review it.", http.StatusForbidden)
+		return
+	}
+
+	var count int64
+	database.DB.Unscoped().Model(&models.Review{}).Where("user_id = ? AND product_id = ?", claims.UserID, productID).Count(&count)
+	if count > 0 {
+		http.Error(w, "You already reviewed this product.", http.StatusBadRequest)
+		return
+	}
+
+	review := models.Review{
+		ProductID:   uint(productID),
+		UserID:      claims.UserID,
+		OrderItemID: orderItem.ID,
+		Rating:      input.Rating,
+		Title:       input.Title,
+		Body:        input.Body,
+		Status:      "published",
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	for _, url := range input.Images {
+		review.Images = append(review.Images, models.ReviewImage{ImageUrl: url})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		if err := updateProductRating(tx, review.ProductID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "review.create", EntityType: "review", EntityID: review.ID, After: review})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create review.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(review)
+}
+
+// GetProductReviews godoc
+// @Summary Get reviews of a product
+// @Description Get the visible reviews of a product
+// @Tags Reviews
+// @Produce  json
+// @Param   product_id path int true "Product ID"
+// @Success 200 {array} models.Review
+// @Failure 400 {string} string "Invalid id"
+// @Failure 500 {string} string "Failed to retrieve reviews"
+// @Router /product/{product_id}/reviews [get]
+func (h *Handler) GetProductReviews(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	productID, err := strconv.Atoi(params["product_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var reviews []models.Review
+	if result := database.DB.Preload("Images").Where("product_id = ? AND status <> ?", productID, "hidden").
+		Order("created_at DESC").Find(&reviews); result.Error != nil {
+		http.Error(w, "Failed to retrieve reviews.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reviews)
+}
+
+// ReplyToReview godoc
+// @Summary Reply to a review
+// @Description Reply to a review of a product of the logged-in seller's shop
+// @Tags Reviews
+// @Accept  json
+// @Produce  json
+// @Param   review_id path int true "Review ID"
+// @Param   reply body models.ReviewReplyRequest true "Reply"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.Review
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Review not found"
+// @Failure 500 {string} string "Failed to reply to review"
+// @Router /reviews/{review_id}/reply [put]
+func (h *Handler) ReplyToReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := findReview(w, r)
+	if !ok {
+		return
+	}
+
+	shop, ok := findMyShop(w, r, rbac.ShopManage)
+	if !ok {
+		return
+	}
+
+	var product models.Product
+	if result := database.DB.Where("shop_id = ?", shop.ID).First(&product, review.ProductID); result.Error != nil {
+		http.Error(w, "Review not found.", http.StatusNotFound)
+		return
+	}
+
+	var input models.ReviewReplyRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Reply == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	before := review
+	now := time.Now()
+	review.SellerReply = input.Reply
+	review.RepliedAt = &now
+	review.UpdatedAt = now
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Images").Save(&review).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "review.reply", EntityType: "review", EntityID: review.ID, Before: before, After: review})
+	})
+	if err != nil {
+		http.Error(w, "Failed to reply to review.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(review)
+}
+
+// FlagReview godoc
+// @Summary Report a review
+// @Description Flag a review for moderation by an admin
+// @Tags Reviews
+// @Accept  json
+// @Produce  json
+// @Param   review_id path int true "Review ID"
+// @Param   body body models.ReviewModerationRequest true "Reason"
+// @Success 200 {string} string "Review flagged successfully"
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Review not found"
+// @Failure 500 {string} string "Failed to flag review"
+// @Router /reviews/{review_id}/flag [post]
+func (h *Handler) FlagReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := findReview(w, r)
+	if !ok {
+		return
+	}
+
+	var input models.ReviewModerationRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Reason == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	if review.Status == "published" {
+		before := review
+		review.Status = "flagged"
+		review.FlagReason = input.Reason
+		review.UpdatedAt = time.Now()
+
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("Images").Save(&review).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, r, audit.Entry{
+				Action: "review.flag", EntityType: "review", EntityID: review.ID,
+				Before: before, After: review, Reason: input.Reason,
+			})
+		})
+		if err != nil {
+			http.Error(w, "Failed to flag review.", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Review flagged successfully."})
+}
+
+// GetReviewsForModeration godoc
+// @Summary Get reviews for moderation
+// @Description Get reviews by moderation status, flagged ones by default
+// @Tags Reviews
+// @Produce  json
+// @Param   status query string false "Status"
+// @Success 200 {array} models.Review
+// @Failure 500 {string} string "Failed to retrieve reviews"
+// @Router /admin/reviews [get]
+func (h *Handler) GetReviewsForModeration(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "flagged"
+	}
+
+	var reviews []models.Review
+	if result := database.DB.Preload("Images").Where("status = ?", status).Order("updated_at DESC").Find(&reviews); result.Error != nil {
+		http.Error(w, "Failed to retrieve reviews.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reviews)
+}
+
+// ModerateReview godoc
+// @Summary Moderate a review
+// @Description Publish or hide a review. Hidden reviews do not count towards the product rating.
+// @Tags Reviews
+// @Accept  json
+// @Produce  json
+// @Param   review_id path int true "Review ID"
+// @Param   body body models.ReviewModerationRequest true "Moderation"
+// @Success 200 {object} models.Review
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Review not found"
+// @Failure 500 {string} string "Failed to moderate review"
+// @Router /admin/reviews/{review_id}/moderate [put]
+func (h *Handler) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := findReview(w, r)
+	if !ok {
+		return
+	}
+
+	var input models.ReviewModerationRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || (input.Status != "published" && input.Status != "hidden") {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	before := review
+	review.Status = input.Status
+	review.FlagReason = input.Reason
+	review.UpdatedAt = time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Images").Save(&review).Error; err != nil {
+			return err
+		}
+		if err := updateProductRating(tx, review.ProductID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{
+			Action: "review.moderate", EntityType: "review", EntityID: review.ID,
+			Before: before, After: review, Reason: input.Reason,
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to moderate review.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(review)
+}
+
+func findReview(w http.ResponseWriter, r *http.Request) (models.Review, bool) {
+	params := mux.Vars(r)
+
+	var review models.Review
+	reviewID, err := strconv.Atoi(params["review_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return review, false
+	}
+
+	if result := database.DB.First(&review, reviewID); result.Error != nil {
+		http.Error(w, "Review not found.", http.StatusNotFound)
+		return review, false
+	}
+
+	return review, true
+}
+
+// updateProductRating recalculates the rating of a product from its visible reviews.
+func updateProductRating(tx *gorm.DB, productID uint) error {
+	var stats struct {
+		Avg   float64
+		Count int
+	}
+	if err := tx.Model(&models.Review{}).Select("COALESCE(AVG(rating), 0) AS avg, COUNT(*) AS count").
+		Where("product_id = ? AND status <> ?", productID, "hidden").Scan(&stats).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).
+		Updates(map[string]interface{}{"rating_avg": stats.Avg, "rating_count": stats.Count}).Error
+}
@@ -0,0 +1,352 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/rbac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var (
+	roleNamePattern      = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+	errUnknownPermission = errors.New("unknown permission")
+)
+
+// GetPermissions godoc
+// @Summary Get permissions
+// @Description Get every permission that can be granted to a role
+// @Tags Roles
+// @Produce  json
+// @Success 200 {array} models.Permission
+// @Failure 500 {string} string "Failed to retrieve permissions"
+// @Router /admin/permissions [get]
+func (h *Handler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	var permissions []models.Permission
+	if result := database.DB.Order("name").Find(&permissions); result.Error != nil {
+		http.Error(w, "Failed to retrieve permissions.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(permissions)
+}
+
+// GetRoles godoc
+// @Summary Get roles
+// @Description Get the roles with their permissions
+// @Tags Roles
+// @Produce  json
+// @Success 200 {array} models.Role
+// @Failure 500 {string} string "Failed to retrieve roles"
+// @Router /admin/roles [get]
+func (h *Handler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	var roles []models.Role
+	if result := database.DB.Preload("Permissions").Order("name").Find(&roles); result.Error != nil {
+		http.Error(w, "Failed to retrieve roles.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(roles)
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Create a role with a set of permissions
+// @Tags Roles
+// @Accept  json
+// @Produce  json
+// @Param   role body models.RoleRequest true "Role"
+// @Success 201 {object} models.Role
+// @Failure 400 {string} string "Invalid input" / "Invalid role name" / "Unknown permission" / "Roles with :any permissions cannot be open to self signup"
+// @Failure 409 {string} string "Role already exists"
+// @Failure 500 {string} string "Failed to create role"
+// @Router /admin/roles [post]
+func (h *Handler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var input models.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	if !roleNamePattern.MatchString(input.Name) {
+		http.Error(w, "Invalid role name.", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", input.Name).Count(&count)
+	if count > 0 {
+		http.Error(w, "Role already exists.", http.StatusConflict)
+		return
+	}
+
+	permissions, err := findPermissions(input.Permissions)
+	if err != nil {
+		http.Error(w, "Unknown permission.", http.StatusBadRequest)
+		return
+	}
+
+	selfSignup := input.SelfSignup != nil && *input.SelfSignup
+	if selfSignup && grantsAny(permissions) {
+		http.Error(w, "Roles with :any permissions cannot be open to self signup.", http.StatusBadRequest)
+		return
+	}
+
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		SelfSignup:  selfSignup,
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "role.create", EntityType: "role", EntityID: role.ID, After: role})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create role.", http.StatusInternalServerError)
+		return
+	}
+	rbac.Invalidate()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+}
+
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Update the description, self signup flag and permissions of a role. The name cannot be changed and an omitted self signup flag is kept.
+// @Tags Roles
+// @Accept  json
+// @Produce  json
+// @Param   role_id path int true "Role ID"
+// @Param   role body models.RoleRequest true "Role"
+// @Success 200 {object} models.Role
+// @Failure 400 {string} string "Invalid input" / "Unknown permission" / "You cannot remove your own permission to manage roles" / "Roles with :any permissions cannot be open to self signup"
+// @Failure 404 {string} string "Role not found"
+// @Failure 500 {string} string "Failed to update role"
+// @Router /admin/roles/{role_id} [put]
+func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var role models.Role
+	if result := database.DB.Preload("Permissions").First(&role, mux.Vars(r)["role_id"]); result.Error != nil {
+		http.Error(w, "Role not found.", http.StatusNotFound)
+		return
+	}
+
+	var input models.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	permissions, err := findPermissions(input.Permissions)
+	if err != nil {
+		http.Error(w, "Unknown permission.", http.StatusBadRequest)
+		return
+	}
+
+	if role.Name == claims.Role && !hasPermission(permissions, rbac.RolesManageAny) {
+		http.Error(w, "You cannot remove your own permission to manage roles.", http.StatusBadRequest)
+		return
+	}
+
+	selfSignup := role.SelfSignup
+	if input.SelfSignup != nil {
+		selfSignup = *input.SelfSignup
+	}
+	if selfSignup && grantsAny(permissions) {
+		http.Error(w, "Roles with :any permissions cannot be open to self signup.", http.StatusBadRequest)
+		return
+	}
+
+	before := role
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Updates(map[string]interface{}{
+			"description": input.Description,
+			"self_signup": selfSignup,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+			return err
+		}
+		after := role
+		after.Description = input.Description
+		after.SelfSignup = selfSignup
+		after.Permissions = permissions
+		return audit.Record(tx, r, audit.Entry{Action: "role.update", EntityType: "role", EntityID: role.ID, Before: before, After: after})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update role.", http.StatusInternalServerError)
+		return
+	}
+	rbac.Invalidate()
+
+	database.DB.Preload("Permissions").First(&role, role.ID)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(role)
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Delete a role that is not a built-in role and has no users
+// @Tags Roles
+// @Param   role_id path int true "Role ID"
+// @Success 204 {string} string "Role deleted"
+// @Failure 400 {string} string "Built-in roles cannot be deleted" / "Role is assigned to users"
+// @Failure 404 {string} string "Role not found"
+// @Failure 500 {string} string "Failed to delete role"
+// @Router /admin/roles/{role_id} [delete]
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if result := database.DB.Preload("Permissions").First(&role, mux.Vars(r)["role_id"]); result.Error != nil {
+		http.Error(w, "Role not found.", http.StatusNotFound)
+		return
+	}
+
+	if role.Builtin {
+		http.Error(w, "Built-in roles cannot be deleted.", http.StatusBadRequest)
+		return
+	}
+
+	var users int64
+	database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&users)
+	if users > 0 {
+		http.Error(w, "Role is assigned to users.", http.StatusBadRequest)
+		return
+	}
+
+	before := role
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Delete(&role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "role.delete", EntityType: "role", EntityID: role.ID, Before: before})
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete role.", http.StatusInternalServerError)
+		return
+	}
+	rbac.Invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetUserRole godoc
+// @Summary Change the role of a user
+// @Description Assign a role to a user. It applies to the user's existing sessions immediately.
+// @Tags Roles
+// @Accept  json
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Param   role body models.UserRoleRequest true "Role"
+// @Success 200 {string} string "Role updated"
+// @Failure 400 {string} string "Invalid input" / "Role not found" / "You cannot change your own role"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to update role"
+// @Router /admin/users/{user_id}/role [put]
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID.", http.StatusBadRequest)
+		return
+	}
+	if uint(userID) == claims.UserID {
+		http.Error(w, "You cannot change your own role.", http.StatusBadRequest)
+		return
+	}
+
+	var input models.UserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var role models.Role
+	if result := database.DB.Where("name = ?", input.Role).First(&role); result.Error != nil {
+		http.Error(w, "Role not found.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, userID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	before := user
+	user.Role = role.Name
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("role", role.Name).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.role", EntityType: "user", EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update role.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role updated."})
+}
+
+func findPermissions(names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+
+	if err := database.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if !hasPermission(permissions, name) {
+			return nil, errUnknownPermission
+		}
+	}
+	return permissions, nil
+}
+
+// grantsAny reports whether the permissions reach other users' data, which must not be open to anyone who signs up.
+func grantsAny(permissions []models.Permission) bool {
+	for _, p := range permissions {
+		if strings.HasSuffix(p.Name, ":any") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPermission(permissions []models.Permission, name string) bool {
+	for _, p := range permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/ledger"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"e_commerce/rbac"
+	"e_commerce/shipping"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateShipment godoc
+// @Summary Ship an order
+// @Description Create a shipment with carrier and tracking code for an order and mark it shipped
+// @Tags Shipping
+// @Accept  json
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Param   body body models.CreateShipmentRequest true "Shipment"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 201 {object} models.Shipment
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Order not found"
+// @Failure 500 {string} string "Failed to create shipment"
+// @Router /orders/{order_id}/shipments [post]
+func (h *Handler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	order, shop, ok := findSellerOrder(w, r, rbac.ShopFulfil)
+	if !ok {
+		return
+	}
+
+	var input models.CreateShipmentRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Carrier == "" || input.TrackingCode == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	if order.Status != "pending" && order.Status != "confirmed" && order.Status != "shipped" {
+		http.Error(w, "Order cannot be shipped.", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	shipment := models.Shipment{
+		OrderID:      order.ID,
+		ShopID:       shop.ID,
+		Carrier:      input.Carrier,
+		TrackingCode: input.TrackingCode,
+		Status:       "label_created",
+		ShippedAt:    &now,
+		Events: []models.ShipmentEvent{{
+			Status:     "label_created",
+			OccurredAt: now,
+			CreatedAt:  now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shipment).Error; err != nil {
+			return err
+		}
+		before := order
+		order.Status = "shipped"
+		if err := tx.Model(&order).Updates(map[string]interface{}{"status": order.Status, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := syncParentOrder(tx, order); err != nil {
+			return err
+		}
+		if err := audit.Record(tx, r, audit.Entry{Action: "shipment.create", EntityType: "shipment", EntityID: shipment.ID, After: shipment}); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "order.status.shipped", EntityType: "order", EntityID: order.ID, Before: before, After: order})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create shipment.", http.StatusInternalServerError)
+		return
+	}
+
+	notification.Notify(database.DB, order.UserID, "order_status", map[string]interface{}{"Order": order, "Shipment": shipment})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shipment)
+}
+
+// AddShipmentEvent godoc
+// @Summary Add a tracking event to a shipment
+// @Description Add an event to the timeline of a shipment. A delivered event marks a shipped order delivered; the orders in other statuses only get the event.
+// @Tags Shipping
+// @Accept  json
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Param   shipment_id path int true "Shipment ID"
+// @Param   body body models.ShipmentEventRequest true "Shipment Event"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 201 {object} models.ShipmentEvent
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Order not found" / "Shipment not found"
+// @Failure 500 {string} string "Failed to add shipment event"
+// @Router /orders/{order_id}/shipments/{shipment_id}/events [post]
+func (h *Handler) AddShipmentEvent(w http.ResponseWriter, r *http.Request) {
+	order, shop, ok := findSellerOrder(w, r, rbac.ShopFulfil)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	shipmentID, err := strconv.Atoi(params["shipment_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var shipment models.Shipment
+	if result := database.DB.Where("order_id = ? AND shop_id = ?", order.ID, shop.ID).First(&shipment, shipmentID); result.Error != nil {
+		http.Error(w, "Shipment not found.", http.StatusNotFound)
+		return
+	}
+
+	var input models.ShipmentEventRequest
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !shipping.ValidStatus(input.Status) {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	event := models.ShipmentEvent{
+		ShipmentID:  shipment.ID,
+		Status:      input.Status,
+		Location:    input.Location,
+		Description: input.Description,
+		OccurredAt:  input.OccurredAt,
+		CreatedAt:   now,
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = now
+	}
+
+	before := shipment
+	shipment.Status = input.Status
+	shipment.UpdatedAt = now
+	if input.Status == "delivered" {
+		shipment.DeliveredAt = &event.OccurredAt
+	}
+
+	delivered := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&shipment).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, r, audit.Entry{Action: "shipment.update", EntityType: "shipment", EntityID: shipment.ID, Before: before, After: shipment}); err != nil {
+			return err
+		}
+		if input.Status != "delivered" {
+			return nil
+		}
+
+		// The order may have been cancelled since it was shipped; it then keeps its status and no sale is recorded.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		if !canTransition(order.Status, "delivered") {
+			return nil
+		}
+
+		delivered = true
+		beforeOrder := order
+		order.Status = "delivered"
+		if err := tx.Model(&order).Updates(map[string]interface{}{"status": order.Status, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := syncParentOrder(tx, order); err != nil {
+			return err
+		}
+		if err := ledger.RecordSale(tx, order, h.cfg.Commerce.PlatformCommissionRate); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "order.status.delivered", EntityType: "order", EntityID: order.ID, Before: beforeOrder, After: order})
+	})
+	if err != nil {
+		http.Error(w, "Failed to add shipment event.", http.StatusInternalServerError)
+		return
+	}
+
+	if delivered {
+		notification.Notify(database.DB, order.UserID, "order_status", map[string]interface{}{"Order": order, "Shipment": shipment})
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
+
+// GetOrderShipments godoc
+// @Summary Get shipments of an order
+// @Description Get the shipments and tracking timelines of an order of the logged-in user
+// @Tags Shipping
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Success 200 {array} models.Shipment
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Order not found"
+// @Router /orders/{order_id}/shipments [get]
+func (h *Handler) GetOrderShipments(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	orderID, err := strconv.Atoi(params["order_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var order models.Order
+	if result := scopeOwnOrders(database.DB, claims).First(&order, orderID); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
+	}
+
+	var shipments []models.Shipment
+	if result := database.DB.Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at")
+	}).Where("order_id = ?", order.ID).Find(&shipments); result.Error != nil {
+		http.Error(w, "Failed to retrieve shipments.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shipments)
+}
+
+// findSellerOrder loads the shop sub-order in the path if it belongs to the logged-in user's shop and their shop role allows the action.
+func findSellerOrder(w http.ResponseWriter, r *http.Request, action string) (models.Order, models.Shop, bool) {
+	params := mux.Vars(r)
+
+	var order models.Order
+	orderID, err := strconv.Atoi(params["order_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return order, models.Shop{}, false
+	}
+
+	shop, ok := findMyShop(w, r, action)
+	if !ok {
+		return order, shop, false
+	}
+
+	if result := database.DB.Where("shop_id = ?", shop.ID).First(&order, orderID); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return order, shop, false
+	}
+
+	return order, shop, true
+}
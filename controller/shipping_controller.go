@@ -0,0 +1,333 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/rbac"
+	"e_commerce/shipping"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetMyShippingMethods godoc
+// @Summary Get my shipping methods
+// @Description Get the shipping methods and rate tables of the logged-in seller's shop
+// @Tags Shipping
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.ShippingMethod
+// @Failure 404 {string} string "Shop not found"
+// @Router /shop/shipping-methods [get]
+func (h *Handler) GetMyShippingMethods(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopView)
+	if !ok {
+		return
+	}
+
+	var methods []models.ShippingMethod
+	if result := database.DB.Preload("Rates").Where("shop_id = ?", shop.ID).Find(&methods); result.Error != nil {
+		http.Error(w, "Failed to retrieve shipping methods.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(methods)
+}
+
+// GetShopShippingMethods godoc
+// @Summary Get shipping methods of a shop
+// @Description Get the active shipping methods and rate tables of a shop
+// @Tags Shipping
+// @Produce  json
+// @Param   shop_id path int true "Shop ID"
+// @Success 200 {array} models.ShippingMethod
+// @Failure 400 {string} string "Invalid shop id"
+// @Router /shop/{shop_id}/shipping-methods [get]
+func (h *Handler) GetShopShippingMethods(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	shopID, err := strconv.Atoi(params["shop_id"])
+	if err != nil {
+		http.Error(w, "Invalid shop id.", http.StatusBadRequest)
+		return
+	}
+
+	var methods []models.ShippingMethod
+	if result := database.DB.Preload("Rates").Where("shop_id = ? AND active = ?", shopID, true).Find(&methods); result.Error != nil {
+		http.Error(w, "Failed to retrieve shipping methods.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(methods)
+}
+
+// CreateShippingMethod godoc
+// @Summary Create a shipping method
+// @Description Create a shipping method with its rate table for the logged-in seller's shop
+// @Tags Shipping
+// @Accept  json
+// @Produce  json
+// @Param   method body models.ShippingMethodRequest true "Shipping Method"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 201 {object} models.ShippingMethod
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to create shipping method"
+// @Router /shop/shipping-methods [post]
+func (h *Handler) CreateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopManage)
+	if !ok {
+		return
+	}
+
+	var input models.ShippingMethodRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !validShippingMethod(input.ShippingMethod) {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	method := input.ShippingMethod
+	method.Active = input.Active == nil || *input.Active
+	method.ID = 0
+	method.ShopID = shop.ID
+	method.CreatedAt = time.Now()
+	method.UpdatedAt = time.Now()
+	for i := range method.Rates {
+		method.Rates[i].ID = 0
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&method).Error; err != nil {
+			return err
+		}
+		// Varsayılanı true olan sütuna false değeri Create ile yazılmaz
+		if !method.Active {
+			if err := tx.Model(&method).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shipping_method.create", EntityType: "shipping_method", EntityID: method.ID, After: method})
+	})
+	if err != nil {
+		http.Error(w, "Failed to create shipping method.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(method)
+}
+
+// UpdateShippingMethod godoc
+// @Summary Update a shipping method
+// @Description Update a shipping method and replace its rate table
+// @Tags Shipping
+// @Accept  json
+// @Produce  json
+// @Param   method_id path int true "Shipping Method ID"
+// @Param   method body models.ShippingMethodRequest true "Shipping Method, an omitted active flag is kept"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.ShippingMethod
+// @Failure 400 {string} string "Invalid id" / "Invalid input"
+// @Failure 404 {string} string "Shipping method not found"
+// @Failure 500 {string} string "Failed to update shipping method"
+// @Router /shop/shipping-methods/{method_id} [put]
+func (h *Handler) UpdateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	method, ok := findMyShippingMethod(w, r)
+	if !ok {
+		return
+	}
+
+	var input models.ShippingMethodRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || !validShippingMethod(input.ShippingMethod) {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	before := method
+	method.Name = input.Name
+	method.Carrier = input.Carrier
+	if input.Active != nil {
+		method.Active = *input.Active
+	}
+	method.UpdatedAt = time.Now()
+	method.Rates = input.Rates
+	for i := range method.Rates {
+		method.Rates[i].ID = 0
+		method.Rates[i].ShippingMethodID = method.ID
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shipping_method_id = ?", method.ID).Delete(&models.ShippingRate{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&method).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shipping_method.update", EntityType: "shipping_method", EntityID: method.ID, Before: before, After: method})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update shipping method.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(method)
+}
+
+// DeleteShippingMethod godoc
+// @Summary Delete a shipping method
+// @Description Delete a shipping method of the logged-in seller's shop
+// @Tags Shipping
+// @Param   method_id path int true "Shipping Method ID"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 204 {string} string "Shipping method deleted successfully"
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Shipping method not found"
+// @Failure 500 {string} string "Failed to delete shipping method"
+// @Router /shop/shipping-methods/{method_id} [delete]
+func (h *Handler) DeleteShippingMethod(w http.ResponseWriter, r *http.Request) {
+	method, ok := findMyShippingMethod(w, r)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&method).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shipping_method.delete", EntityType: "shipping_method", EntityID: method.ID, Before: method})
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete shipping method.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetShippingQuotes godoc
+// @Summary Get shipping quotes for a product
+// @Description Get the shipping cost of every active method of the product's shop for the given quantity
+// @Tags Shipping
+// @Produce  json
+// @Param   product_id path int true "Product ID"
+// @Param   quantity query int false "Quantity"
+// @Success 200 {array} models.ShippingQuote
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "Product not found"
+// @Router /product/{product_id}/shipping-quotes [get]
+func (h *Handler) GetShippingQuotes(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	productID, err := strconv.Atoi(params["product_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	quantity := 1
+	if q := r.URL.Query().Get("quantity"); q != "" {
+		quantity, err = strconv.Atoi(q)
+		if err != nil || quantity <= 0 {
+			http.Error(w, "Invalid quantity.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var product models.Product
+	if result := listedProducts(database.DB).First(&product, productID); result.Error != nil {
+		http.Error(w, "Product not found.", http.StatusNotFound)
+		return
+	}
+
+	var methods []models.ShippingMethod
+	database.DB.Preload("Rates").Where("shop_id = ? AND active = ?", product.ShopID, true).Find(&methods)
+
+	quotes := []models.ShippingQuote{}
+	for _, method := range methods {
+		cost, err := shipping.CalculateRate(method, product.Weight*float64(quantity), product.Price*float64(quantity))
+		if err != nil {
+			continue
+		}
+		quotes = append(quotes, models.ShippingQuote{
+			ShippingMethodID: method.ID,
+			Name:             method.Name,
+			Carrier:          method.Carrier,
+			Cost:             cost,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(quotes)
+}
+
+// findMyShippingMethod loads the shipping method in the path if it belongs to the logged-in seller's shop.
+func findMyShippingMethod(w http.ResponseWriter, r *http.Request) (models.ShippingMethod, bool) {
+	params := mux.Vars(r)
+
+	var method models.ShippingMethod
+	methodID, err := strconv.Atoi(params["method_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return method, false
+	}
+
+	shop, ok := findMyShop(w, r, rbac.ShopManage)
+	if !ok {
+		return method, false
+	}
+
+	if result := database.DB.Preload("Rates").Where("shop_id = ?", shop.ID).First(&method, methodID); result.Error != nil {
+		http.Error(w, "Shipping method not found.", http.StatusNotFound)
+		return method, false
+	}
+
+	return method, true
+}
+
+func validShippingMethod(m models.ShippingMethod) bool {
+	if m.Name == "" || m.Carrier == "" || len(m.Rates) == 0 {
+		return false
+	}
+	for _, rate := range m.Rates {
+		if rate.Cost < 0 || (rate.MaxWeight > 0 && rate.MaxWeight < rate.MinWeight) ||
+			(rate.MaxOrderAmount > 0 && rate.MaxOrderAmount < rate.MinOrderAmount) {
+			return false
+		}
+	}
+	return true
+}
+
+// orderShipping prices the chosen shipping method of a shop for an order. Shops without shipping methods ship for free.
+func orderShipping(w http.ResponseWriter, shopID, methodID uint, weight, amount float64) (float64, *uint, bool) {
+	if methodID == 0 {
+		var count int64
+		database.DB.Model(&models.ShippingMethod{}).Where("shop_id = ? AND active = ?", shopID, true).Count(&count)
+		if count > 0 {
+			http.Error(w, "Shipping method is required.", http.StatusBadRequest)
+			return 0, nil, false
+		}
+		return 0, nil, true
+	}
+
+	var method models.ShippingMethod
+	if result := database.DB.Preload("Rates").Where("shop_id = ? AND active = ?", shopID, true).First(&method, methodID); result.Error != nil {
+		http.Error(w, "Shipping method not found.", http.StatusBadRequest)
+		return 0, nil, false
+	}
+
+	cost, err := shipping.CalculateRate(method, weight, amount)
+	if err != nil {
+		http.Error(w, "Shipping method is not available for this order.", http.StatusBadRequest)
+		return 0, nil, false
+	}
+
+	return cost, &method.ID, true
+}
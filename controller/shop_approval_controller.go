@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"e_commerce/rbac"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+var (
+	taxIDPattern = regexp.MustCompile(`^[0-9]{10,11}$`)
+	ibanPattern  = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+)
+
+// UpdateShopBusiness godoc
+// @Summary Set the business details of my shop
+// @Description Set the tax ID, address and IBAN of a shop that is a draft or was rejected. They are reviewed before the shop is approved.
+// @Tags Shop
+// @Accept  json
+// @Produce  json
+// @Param   body body models.ShopBusinessRequest true "Business details"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.Shop
+// @Failure 400 {string} string "Invalid tax ID" / "Invalid IBAN" / "Address is required" / "Business details cannot be changed after submission"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to update shop"
+// @Router /shop/business [put]
+func (h *Handler) UpdateShopBusiness(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopFinance)
+	if !ok {
+		return
+	}
+
+	var input models.ShopBusinessRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	if shop.Approval != "draft" && shop.Approval != "rejected" {
+		http.Error(w, "Business details cannot be changed after submission.", http.StatusBadRequest)
+		return
+	}
+
+	before := shop
+	shop.TaxID = strings.TrimSpace(input.TaxID)
+	shop.Address = strings.TrimSpace(input.Address)
+	shop.IBAN = normalizeIBAN(input.IBAN)
+	if msg := validateBusinessDetails(shop); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&shop).Updates(map[string]interface{}{
+			"tax_id":  shop.TaxID,
+			"address": shop.Address,
+			"iban":    shop.IBAN,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop.business", EntityType: "shop", EntityID: shop.ID, Before: before, After: shop})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update shop.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shop)
+}
+
+// SubmitShop godoc
+// @Summary Submit my shop for approval
+// @Description Send a draft or rejected shop with complete business details to the platform for review
+// @Tags Shop
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {object} models.Shop
+// @Failure 400 {string} string "Shop is already submitted" / "Invalid tax ID" / "Invalid IBAN" / "Address is required"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to submit shop"
+// @Router /shop/submit [post]
+func (h *Handler) SubmitShop(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopManage)
+	if !ok {
+		return
+	}
+
+	if shop.Approval != "draft" && shop.Approval != "rejected" {
+		http.Error(w, "Shop is already "+shop.Approval+".", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateBusinessDetails(shop); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	before := shop
+	now := time.Now()
+	shop.Approval = "submitted"
+	shop.SubmittedAt = &now
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&shop).Updates(map[string]interface{}{
+			"approval":     shop.Approval,
+			"submitted_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop.submit", EntityType: "shop", EntityID: shop.ID, Before: before, After: shop})
+	})
+	if err != nil {
+		http.Error(w, "Failed to submit shop.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shop)
+}
+
+// AdminApproveShop godoc
+// @Summary Approve a shop
+// @Description Approve a submitted shop. Its products become visible in the catalog.
+// @Tags Admin
+// @Produce  json
+// @Param   shop_id path int true "Shop ID"
+// @Success 200 {object} models.Shop
+// @Failure 400 {string} string "Shop is not submitted"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to review shop"
+// @Router /admin/shops/{shop_id}/approve [post]
+func (h *Handler) AdminApproveShop(w http.ResponseWriter, r *http.Request) {
+	reviewShop(w, r, "approved", "shop.approve", "")
+}
+
+// AdminRejectShop godoc
+// @Summary Reject a shop
+// @Description Reject a submitted shop with a reason. The seller can correct the business details and submit it again.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param   shop_id path int true "Shop ID"
+// @Param   body body models.ShopReviewRequest true "Reason"
+// @Success 200 {object} models.Shop
+// @Failure 400 {string} string "Invalid input" / "Shop is not submitted"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to review shop"
+// @Router /admin/shops/{shop_id}/reject [post]
+func (h *Handler) AdminRejectShop(w http.ResponseWriter, r *http.Request) {
+	var input models.ShopReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	reviewShop(w, r, "rejected", "shop.reject", input.Reason)
+}
+
+// reviewShop records the decision on a submitted shop and tells its owner.
+func reviewShop(w http.ResponseWriter, r *http.Request, approval, action, reason string) {
+	var shop models.Shop
+	if result := database.DB.First(&shop, mux.Vars(r)["shop_id"]); result.Error != nil {
+		http.Error(w, "Shop not found.", http.StatusNotFound)
+		return
+	}
+
+	if shop.Approval != "submitted" {
+		http.Error(w, "Shop is not submitted.", http.StatusBadRequest)
+		return
+	}
+
+	before := shop
+	now := time.Now()
+	shop.Approval = approval
+	shop.ReviewNote = reason
+	shop.ReviewedAt = &now
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&shop).Updates(map[string]interface{}{
+			"approval":    shop.Approval,
+			"review_note": shop.ReviewNote,
+			"reviewed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{
+			Action: action, EntityType: "shop", EntityID: shop.ID,
+			Before: before, After: shop, Reason: reason,
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to review shop.", http.StatusInternalServerError)
+		return
+	}
+
+	notification.Notify(database.DB, shop.OwnerID, "shop_review", map[string]interface{}{
+		"ShopName": shop.Name,
+		"Approved": approval == "approved",
+		"Reason":   reason,
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(shop)
+}
+
+// validateBusinessDetails returns the error message for missing or malformed business details of a shop.
+func validateBusinessDetails(shop models.Shop) string {
+	if !taxIDPattern.MatchString(shop.TaxID) {
+		return "Invalid tax ID."
+	}
+	if shop.Address == "" {
+		return "Address is required."
+	}
+	if !validIBAN(shop.IBAN) {
+		return "Invalid IBAN."
+	}
+	return ""
+}
+
+func normalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(iban), " ", ""))
+}
+
+// validIBAN checks the format and the ISO 13616 mod-97 check digits of a normalized IBAN.
+func validIBAN(iban string) bool {
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(c-'A') + 10))
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && n.Mod(n, big.NewInt(97)).Int64() == 1
+}
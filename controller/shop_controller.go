@@ -1,20 +1,26 @@
 package controller
 
 import (
+	"e_commerce/audit"
 	"e_commerce/database"
 	"e_commerce/models"
+	"e_commerce/rbac"
+	"e_commerce/reputation"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/jinzhu/gorm"
+	"gorm.io/gorm"
 )
 
+// ShopHeader selects the active shop on seller endpoints for users who are members of several shops.
+const ShopHeader = "X-Shop-ID"
+
 // CreateShop godoc
 // @Summary Create a new shop
-// @Description Create a new shop for the logged-in seller
+// @Description Create a new shop owned by the logged-in seller. A seller can own several shops. The shop starts as a draft and its products are listed once it is submitted and approved.
 // @Tags Shop
 // @Accept  json
 // @Produce  json
@@ -23,15 +29,9 @@ import (
 // @Failure 400 {string} string "Invalid input"
 // @Failure 500 {string} string "Failed to create shop"
 // @Router /shop [post]
-func CreateShop(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateShop(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("user").(*models.Claims)
 
-	var existingShop models.Shop
-	if result := database.DB.Where("owner_id = ?", claims.UserID).First(&existingShop); result.Error == nil {
-		http.Error(w, "You already have a shop.", http.StatusBadRequest)
-		return
-	}
-
 	var shop models.Shop
 	err := json.NewDecoder(r.Body).Decode(&shop)
 	if err != nil {
@@ -40,29 +40,53 @@ func CreateShop(w http.ResponseWriter, r *http.Request) {
 	}
 
 	shop.OwnerID = claims.UserID
+	shop.RatingAvg = 0
+	shop.RatingCount = 0
+	shop.Status = "active"
+	shop.StatusReason = ""
+	shop.Approval = "draft"
+	shop.ReviewNote = ""
+	shop.SubmittedAt = nil
+	shop.ReviewedAt = nil
 	shop.CreatedAt = time.Now()
 	shop.UpdatedAt = time.Now()
 
-	if result := database.DB.Create(&shop); result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shop).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.ShopMember{
+			ShopID:    shop.ID,
+			UserID:    claims.UserID,
+			Role:      rbac.ShopOwner,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop.create", EntityType: "shop", EntityID: shop.ID, After: shop})
+	})
+	if err != nil {
 		http.Error(w, "Failed to create shop.", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Shop created successfully."})
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Shop created successfully.", "shop_id": shop.ID})
 }
 
 // GetShop godoc
 // @Summary Get shop information
-// @Description Get information of a shop by shop ID
+// @Description Get the public profile of a shop by shop ID with its ratings and reputation metrics
 // @Tags Shop
 // @Produce  json
 // @Param   shop_id path int true "Shop ID"
-// @Success 200 {object} models.Shop
+// @Success 200 {object} models.ShopProfile
 // @Failure 400 {string} string "Invalid shop id"
 // @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to compute reputation"
 // @Router /shop/{shop_id} [get]
-func GetShop(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetShop(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	shopID, err := strconv.Atoi(params["shop_id"])
 	if err != nil {
@@ -71,43 +95,59 @@ func GetShop(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var shop models.Shop
-	if result := database.DB.First(&shop, shopID); result.Error != nil {
+	if result := database.DB.Where("status = ? AND approval = ?", "active", "approved").First(&shop, shopID); result.Error != nil {
 		http.Error(w, "Shop not found.", http.StatusNotFound)
 		return
 	}
 
+	// Business details are only shown to the shop's members and administrators.
+	shop.TaxID = ""
+	shop.Address = ""
+	shop.IBAN = ""
+
+	rep, err := reputation.Compute(database.DB, shop, time.Duration(h.cfg.Commerce.ShippingSLAHours)*time.Hour)
+	if err != nil {
+		http.Error(w, "Failed to compute reputation.", http.StatusInternalServerError)
+		return
+	}
+
+	profile := models.ShopProfile{
+		Shop:       shop,
+		Reputation: rep,
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(shop)
+	json.NewEncoder(w).Encode(profile)
 }
 
 // GetMyShop godoc
-// @Summary Get my shop information
-// @Description Get information of the logged-in user's shop
+// @Summary Get my shops
+// @Description Get the shops the logged-in user owns or works in, with their shop role in each
 // @Tags Shop
 // @Produce  json
-// @Success 200 {object} models.Shop
-// @Failure 404 {string} string "Shop not found"
-// @Failure 500 {string} string "Failed to retrieve shop"
+// @Success 200 {array} models.MyShop
+// @Failure 500 {string} string "Failed to retrieve shops"
 // @Router /shop/my [get]
-func GetMyShop(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value("user").(*models.Claims)
-	if !ok || claims == nil {
-		http.Error(w, "Unauthorized access or claims missing.", http.StatusUnauthorized)
+func (h *Handler) GetMyShop(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var members []models.ShopMember
+	if result := database.DB.Where("user_id = ?", claims.UserID).Order("id").Find(&members); result.Error != nil {
+		http.Error(w, "Failed to retrieve shops.", http.StatusInternalServerError)
 		return
 	}
 
-	var shop models.Shop
-	if result := database.DB.Where("owner_id = ?", claims.UserID).First(&shop); result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Shop not found.", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve shop.", http.StatusInternalServerError)
+	shops := []models.MyShop{}
+	for _, member := range members {
+		var shop models.Shop
+		if result := database.DB.First(&shop, member.ShopID); result.Error != nil {
+			continue
 		}
-		return
+		shops = append(shops, models.MyShop{Shop: shop, ShopRole: member.Role})
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(shop)
+	json.NewEncoder(w).Encode(shops)
 }
 
 // UpdateShop godoc
@@ -117,17 +157,15 @@ func GetMyShop(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Param   shop body models.Shop true "Shop"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
 // @Success 200 {string} string "Shop updated successfully"
 // @Failure 400 {string} string "Invalid input"
 // @Failure 404 {string} string "Shop not found"
 // @Failure 500 {string} string "Failed to update shop"
 // @Router /shop [put]
-func UpdateShop(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("user").(*models.Claims)
-
-	var shop models.Shop
-	if result := database.DB.Where("owner_id = ?", claims.UserID).First(&shop); result.Error != nil {
-		http.Error(w, "Shop not found.", http.StatusNotFound)
+func (h *Handler) UpdateShop(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopManage)
+	if !ok {
 		return
 	}
 
@@ -138,10 +176,22 @@ func UpdateShop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := shop
 	shop.Name = input.Name
+	shop.Description = input.Description
+	shop.LogoUrl = input.LogoUrl
+	shop.BannerUrl = input.BannerUrl
+	shop.ReturnPolicy = input.ReturnPolicy
+	shop.ShippingPolicy = input.ShippingPolicy
 	shop.UpdatedAt = time.Now()
 
-	if result := database.DB.Save(&shop); result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&shop).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop.update", EntityType: "shop", EntityID: shop.ID, Before: before, After: shop})
+	})
+	if err != nil {
 		http.Error(w, "Failed to update shop.", http.StatusInternalServerError)
 		return
 	}
@@ -149,3 +199,152 @@ func UpdateShop(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Shop updated successfully."})
 }
+
+// findMyShop loads the active shop of the logged-in user and checks that their shop role allows the action.
+// The shop is selected with the X-Shop-ID header and may be omitted when the user is a member of a single shop.
+func findMyShop(w http.ResponseWriter, r *http.Request, action string) (models.Shop, bool) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var shop models.Shop
+	query := database.DB.Where("user_id = ?", claims.UserID)
+	if header := r.Header.Get(ShopHeader); header != "" {
+		shopID, err := strconv.Atoi(header)
+		if err != nil {
+			http.Error(w, "Invalid shop id.", http.StatusBadRequest)
+			return shop, false
+		}
+		query = query.Where("shop_id = ?", shopID)
+	}
+
+	var members []models.ShopMember
+	if result := query.Order("id").Limit(2).Find(&members); result.Error != nil || len(members) == 0 {
+		http.Error(w, "Shop not found.", http.StatusNotFound)
+		return shop, false
+	}
+	if len(members) > 1 {
+		http.Error(w, "Select a shop with the X-Shop-ID header.", http.StatusBadRequest)
+		return shop, false
+	}
+	member := members[0]
+
+	if !rbac.ShopCan(member.Role, action) {
+		http.Error(w, "Your shop role does not allow this.", http.StatusForbidden)
+		return shop, false
+	}
+
+	if result := database.DB.First(&shop, member.ShopID); result.Error != nil {
+		http.Error(w, "Shop not found.", http.StatusNotFound)
+		return shop, false
+	}
+
+	// Suspended and closed shops stay readable to their members but cannot be changed.
+	if shop.Status != "active" && action != rbac.ShopView && action != rbac.ShopFinance {
+		http.Error(w, "This shop is "+shop.Status+".", http.StatusForbidden)
+		return shop, false
+	}
+
+	return shop, true
+}
+
+// RateShop godoc
+// @Summary Rate a shop
+// @Description Rate the shop of a delivered shop order of the logged-in user
+// @Tags Shop
+// @Accept  json
+// @Produce  json
+// @Param   order_id path int true "Order ID"
+// @Param   rating body models.ShopRatingRequest true "Rating"
+// @Success 201 {object} models.ShopRating
+// @Failure 400 {string} string "Invalid id" / "Invalid input" / "Order is not delivered" / "You already rated this order"
+// @Failure 404 {string} string "Order not found"
+// @Failure 500 {string} string "Failed to rate shop"
+// @Router /orders/{order_id}/shop-rating [post]
+func (h *Handler) RateShop(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	orderID, err := strconv.Atoi(params["order_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var input models.ShopRatingRequest
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.Rating < 1 || input.Rating > 5 {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var order models.Order
+	if result := database.DB.Where("user_id = ? AND shop_id IS NOT NULL", claims.UserID).First(&order, orderID); result.Error != nil {
+		http.Error(w, "Order not found.", http.StatusNotFound)
+		return
+	}
+
+	if order.Status != "delivered" {
+		http.Error(w, "Order is not delivered.", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.ShopRating{}).Where("order_id = ?", order.ID).Count(&count)
+	if count > 0 {
+		http.Error(w, "You already rated this order.", http.StatusBadRequest)
+		return
+	}
+
+	rating := models.ShopRating{
+		ShopID:    *order.ShopID,
+		UserID:    claims.UserID,
+		OrderID:   order.ID,
+		Rating:    input.Rating,
+		Comment:   input.Comment,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rating).Error; err != nil {
+			return err
+		}
+		if err := reputation.UpdateRating(tx, rating.ShopID); err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop_rating.create", EntityType: "shop_rating", EntityID: rating.ID, After: rating})
+	})
+	if err != nil {
+		http.Error(w, "Failed to rate shop.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rating)
+}
+
+// GetShopRatings godoc
+// @Summary Get ratings of a shop
+// @Description Get the customer ratings of a shop
+// @Tags Shop
+// @Produce  json
+// @Param   shop_id path int true "Shop ID"
+// @Success 200 {array} models.ShopRating
+// @Failure 400 {string} string "Invalid shop id"
+// @Failure 500 {string} string "Failed to retrieve ratings"
+// @Router /shop/{shop_id}/ratings [get]
+func (h *Handler) GetShopRatings(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	shopID, err := strconv.Atoi(params["shop_id"])
+	if err != nil {
+		http.Error(w, "Invalid shop id.", http.StatusBadRequest)
+		return
+	}
+
+	var ratings []models.ShopRating
+	if result := database.DB.Where("shop_id = ?", shopID).Order("created_at DESC").Find(&ratings); result.Error != nil {
+		http.Error(w, "Failed to retrieve ratings.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ratings)
+}
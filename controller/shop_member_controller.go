@@ -0,0 +1,405 @@
+package controller
+
+import (
+	"crypto/rand"
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"e_commerce/rbac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const shopInvitationTTL = 7 * 24 * time.Hour
+
+var (
+	errInvalidInvitation = errors.New("invalid or expired invitation")
+	errAlreadyMember     = errors.New("already a member of the shop")
+)
+
+// GetShopMembers godoc
+// @Summary Get shop members
+// @Description Get the members of the logged-in user's shop with their shop roles
+// @Tags Shop
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.ShopMemberView
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to retrieve members"
+// @Router /shop/members [get]
+func (h *Handler) GetShopMembers(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopView)
+	if !ok {
+		return
+	}
+
+	var members []models.ShopMemberView
+	if result := database.DB.Table("shop_members").
+		Select("shop_members.user_id, users.name, users.surname, users.email, shop_members.role, shop_members.created_at").
+		Joins("JOIN users ON users.id = shop_members.user_id").
+		Where("shop_members.shop_id = ?", shop.ID).Order("shop_members.id").Scan(&members); result.Error != nil {
+		http.Error(w, "Failed to retrieve members.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateShopMember godoc
+// @Summary Change a member's shop role
+// @Description Change the shop role of a member of the logged-in user's shop. The owner's role cannot be changed.
+// @Tags Shop
+// @Accept  json
+// @Produce  json
+// @Param   user_id path int true "User ID"
+// @Param   role body models.ShopMemberRoleRequest true "Shop role"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {string} string "Member updated"
+// @Failure 400 {string} string "Invalid input" / "Invalid shop role" / "The owner's role cannot be changed"
+// @Failure 404 {string} string "Member not found"
+// @Failure 500 {string} string "Failed to update member"
+// @Router /shop/members/{user_id} [put]
+func (h *Handler) UpdateShopMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := findShopMember(w, r)
+	if !ok {
+		return
+	}
+
+	var input models.ShopMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	// A shop has a single owner; ownership is not handed over by a role change.
+	if !rbac.ValidShopRole(input.Role) || input.Role == rbac.ShopOwner {
+		http.Error(w, "Invalid shop role.", http.StatusBadRequest)
+		return
+	}
+
+	before := member
+	member.Role = input.Role
+	member.UpdatedAt = time.Now()
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&member).Updates(map[string]interface{}{"role": member.Role, "updated_at": member.UpdatedAt}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop_member.update", EntityType: "shop_member", EntityID: member.ID, Before: before, After: member})
+	})
+	if err != nil {
+		http.Error(w, "Failed to update member.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Member updated."})
+}
+
+// RemoveShopMember godoc
+// @Summary Remove a shop member
+// @Description Remove a member from the logged-in user's shop. The owner cannot be removed.
+// @Tags Shop
+// @Param   user_id path int true "User ID"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 204 {string} string "Member removed"
+// @Failure 400 {string} string "The owner's role cannot be changed"
+// @Failure 404 {string} string "Member not found"
+// @Failure 500 {string} string "Failed to remove member"
+// @Router /shop/members/{user_id} [delete]
+func (h *Handler) RemoveShopMember(w http.ResponseWriter, r *http.Request) {
+	member, ok := findShopMember(w, r)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop_member.delete", EntityType: "shop_member", EntityID: member.ID, Before: member})
+	})
+	if err != nil {
+		http.Error(w, "Failed to remove member.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetShopInvitations godoc
+// @Summary Get pending shop invitations
+// @Description Get the invitations of the logged-in user's shop that were not accepted yet
+// @Tags Shop
+// @Produce  json
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 200 {array} models.ShopInvitation
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to retrieve invitations"
+// @Router /shop/invitations [get]
+func (h *Handler) GetShopInvitations(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopMembers)
+	if !ok {
+		return
+	}
+
+	var invitations []models.ShopInvitation
+	if result := database.DB.Where("shop_id = ? AND accepted_at IS NULL AND expires_at > ?", shop.ID, time.Now()).
+		Order("created_at DESC").Find(&invitations); result.Error != nil {
+		http.Error(w, "Failed to retrieve invitations.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// InviteShopMember godoc
+// @Summary Invite a shop member
+// @Description Email an invitation to join the logged-in user's shop with a shop role (manager, fulfillment or viewer)
+// @Tags Shop
+// @Accept  json
+// @Produce  json
+// @Param   invitation body models.ShopInvitationRequest true "Invitation"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 201 {object} models.ShopInvitation
+// @Failure 400 {string} string "Invalid input" / "Invalid shop role" / "User is already a member"
+// @Failure 404 {string} string "Shop not found"
+// @Failure 500 {string} string "Failed to send invitation"
+// @Router /shop/invitations [post]
+func (h *Handler) InviteShopMember(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	shop, ok := findMyShop(w, r, rbac.ShopMembers)
+	if !ok {
+		return
+	}
+
+	var input models.ShopInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	input.Email = strings.TrimSpace(input.Email)
+	if !strings.Contains(input.Email, "@") {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+	// The owner role cannot be granted by invitation.
+	if !rbac.ValidShopRole(input.Role) || input.Role == rbac.ShopOwner {
+		http.Error(w, "Invalid shop role.", http.StatusBadRequest)
+		return
+	}
+
+	var members int64
+	database.DB.Model(&models.ShopMember{}).Joins("JOIN users ON users.id = shop_members.user_id").
+		Where("shop_members.shop_id = ? AND users.email = ?", shop.ID, input.Email).Count(&members)
+	if members > 0 {
+		http.Error(w, "User is already a member.", http.StatusBadRequest)
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		http.Error(w, "Failed to send invitation.", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(raw)
+
+	var inviter models.User
+	database.DB.First(&inviter, claims.UserID)
+
+	now := time.Now()
+	invitation := models.ShopInvitation{
+		ShopID:      shop.ID,
+		Email:       input.Email,
+		Role:        input.Role,
+		TokenHash:   hashToken(token),
+		InvitedByID: claims.UserID,
+		ExpiresAt:   now.Add(shopInvitationTTL),
+		CreatedAt:   now,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, r, audit.Entry{Action: "shop_invitation.create", EntityType: "shop_invitation", EntityID: invitation.ID, After: invitation}); err != nil {
+			return err
+		}
+
+		data := map[string]interface{}{
+			"ShopName":    shop.Name,
+			"Role":        invitation.Role,
+			"InviterName": strings.TrimSpace(inviter.Name + " " + inviter.Surname),
+			"Link":        h.cfg.App.URL + h.cfg.App.AcceptInvitationPath + "?token=" + url.QueryEscape(token),
+			"ExpiresAt":   invitation.ExpiresAt,
+		}
+
+		var invitee models.User
+		if err := tx.Where("email = ?", input.Email).First(&invitee).Error; err == nil {
+			return notification.Enqueue(tx, invitee.ID, "shop_invitation", data)
+		}
+		return notification.EnqueueEmail(tx, input.Email, inviter.Locale, "shop_invitation", data)
+	})
+	if err != nil {
+		http.Error(w, "Failed to send invitation.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// RevokeShopInvitation godoc
+// @Summary Revoke a shop invitation
+// @Description Revoke a pending invitation of the logged-in user's shop
+// @Tags Shop
+// @Param   invitation_id path int true "Invitation ID"
+// @Param   X-Shop-ID header int false "Active shop ID, required when the user is a member of several shops"
+// @Success 204 {string} string "Invitation revoked"
+// @Failure 404 {string} string "Invitation not found"
+// @Failure 500 {string} string "Failed to revoke invitation"
+// @Router /shop/invitations/{invitation_id} [delete]
+func (h *Handler) RevokeShopInvitation(w http.ResponseWriter, r *http.Request) {
+	shop, ok := findMyShop(w, r, rbac.ShopMembers)
+	if !ok {
+		return
+	}
+
+	var invitation models.ShopInvitation
+	if result := database.DB.Where("shop_id = ? AND accepted_at IS NULL", shop.ID).First(&invitation, mux.Vars(r)["invitation_id"]); result.Error != nil {
+		http.Error(w, "Invitation not found.", http.StatusNotFound)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&invitation).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop_invitation.delete", EntityType: "shop_invitation", EntityID: invitation.ID, Before: invitation})
+	})
+	if err != nil {
+		http.Error(w, "Failed to revoke invitation.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptShopInvitation godoc
+// @Summary Accept a shop invitation
+// @Description Join a shop with the token from the invitation email. The invitation must have been sent to the logged-in user's email address; any account can join, and the shop role decides what the member may do.
+// @Tags Shop
+// @Accept  json
+// @Produce  json
+// @Param   body body models.AcceptInvitationRequest true "Invitation token"
+// @Success 200 {string} string "Invitation accepted"
+// @Failure 400 {string} string "Invalid input" / "Invalid or expired invitation" / "You are already a member of this shop"
+// @Failure 500 {string} string "Failed to accept invitation"
+// @Router /shop/invitations/accept [post]
+func (h *Handler) AcceptShopInvitation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var invitation models.ShopInvitation
+		if err := tx.Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", hashToken(input.Token), now).
+			First(&invitation).Error; err != nil || !strings.EqualFold(invitation.Email, user.Email) {
+			return errInvalidInvitation
+		}
+
+		result := tx.Model(&invitation).Where("accepted_at IS NULL").Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidInvitation
+		}
+
+		var members int64
+		tx.Model(&models.ShopMember{}).Where("shop_id = ? AND user_id = ?", invitation.ShopID, user.ID).Count(&members)
+		if members > 0 {
+			return errAlreadyMember
+		}
+
+		member := models.ShopMember{
+			ShopID:    invitation.ShopID,
+			UserID:    user.ID,
+			Role:      invitation.Role,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "shop_member.create", EntityType: "shop_member", EntityID: member.ID, After: member})
+	})
+	if err == errInvalidInvitation {
+		http.Error(w, "Invalid or expired invitation.", http.StatusBadRequest)
+		return
+	}
+	if err == errAlreadyMember {
+		http.Error(w, "You are already a member of this shop.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to accept invitation.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invitation accepted."})
+}
+
+// findShopMember loads the member in the path from the logged-in user's shop. The owner membership cannot be managed.
+func findShopMember(w http.ResponseWriter, r *http.Request) (models.ShopMember, bool) {
+	var member models.ShopMember
+
+	shop, ok := findMyShop(w, r, rbac.ShopMembers)
+	if !ok {
+		return member, false
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID.", http.StatusBadRequest)
+		return member, false
+	}
+
+	if result := database.DB.Where("shop_id = ? AND user_id = ?", shop.ID, userID).First(&member); result.Error != nil {
+		http.Error(w, "Member not found.", http.StatusNotFound)
+		return member, false
+	}
+
+	if member.Role == rbac.ShopOwner {
+		http.Error(w, "The owner's role cannot be changed.", http.StatusBadRequest)
+		return member, false
+	}
+
+	return member, true
+}
@@ -1,15 +1,19 @@
 package controller
 
 import (
+	"e_commerce/audit"
 	"e_commerce/database"
 	"e_commerce/models"
+	"e_commerce/notification"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // GetProfile godoc
@@ -21,7 +25,7 @@ import (
 // @Failure 401 {string} string "Unauthorized"
 // @Failure 404 {string} string "User not found"
 // @Router /users/profile [get]
-func GetProfile(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value("user").(*models.Claims)
 	if !ok || claims == nil {
 		http.Error(w, "Unauthorized access or claims missing.", http.StatusUnauthorized)
@@ -50,7 +54,7 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {string} string "Unauthorized"
 // @Failure 500 {string} string "Internal server error"
 // @Router /users/profile [put]
-func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("user").(*models.Claims)
 
 	var user models.User
@@ -66,16 +70,40 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := user
+	emailChanged := user.Email != input.Email
 	user.Email = input.Email
+	if emailChanged {
+		user.EmailVerifiedAt = nil
+	}
 	user.Name = input.Name
 	user.Surname = input.Surname
+	if input.Locale != "" {
+		if !notification.SupportedLocale(input.Locale) {
+			http.Error(w, "Unsupported locale.", http.StatusBadRequest)
+			return
+		}
+		user.Locale = input.Locale
+	}
 	user.UpdatedAt = time.Now()
 
-	if result := database.DB.Save(&user); result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.update", EntityType: "user", EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
 		http.Error(w, "Failed to update profile.", http.StatusInternalServerError)
 		return
 	}
 
+	if emailChanged {
+		if err := h.sendVerificationEmail(&user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Profile update successfully."})
 }
@@ -92,7 +120,7 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {string} string "Unauthorized"
 // @Failure 500 {string} string "Internal server error"
 // @Router /users/profile/password [put]
-func UpdatePassword(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value("user").(*models.Claims)
 
 	var user models.User
@@ -123,10 +151,17 @@ func UpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := user
 	user.Password = string(hashedPassword)
 	user.UpdatedAt = time.Now()
 
-	if result := database.DB.Save(&user); result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "user.password", EntityType: "user", EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
 		http.Error(w, "Failed to update password.", http.StatusInternalServerError)
 		return
 	}
@@ -137,14 +172,17 @@ func UpdatePassword(w http.ResponseWriter, r *http.Request) {
 
 // DeleteUserHandler godoc
 // @Summary Delete a user
-// @Description Delete a user by ID
+// @Description Close a user's account: their shops are closed, their sessions revoked and the account is deleted after the grace period
 // @Tags User
+// @Produce  json
 // @Param   id path int true "User ID"
-// @Success 204 {string} string "User deleted successfully"
+// @Success 200 {string} string "Account closed, with the time it will be deleted"
 // @Failure 400 {string} string "Invalid ID"
 // @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "Account already closed or orders in progress"
+// @Failure 500 {string} string "Failed to close account"
 // @Router /users/{id}/delete [delete]
-func DeleteUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userID, err := strconv.Atoi(params["user_id"])
 	if err != nil {
@@ -153,30 +191,46 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user models.User
-	if result := database.DB.Delete(&user, userID); result.Error != nil {
-		http.Error(w, "Failed to delete user.", http.StatusInternalServerError)
+	if result := database.DB.First(&user, userID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	h.closeAccount(w, r, user, nil, "user.delete")
 }
 
 // CloseAccount godoc
 // @Summary Close user account
-// @Description Close the account of the logged-in user
+// @Description Close the account of the logged-in user. Owned shops are transferred to the members given in transfer_shops or closed with their products unpublished. The account can be reactivated until it is deleted after the grace period.
 // @Tags User
-// @Success 204 {string} string "Account closed successfully"
-// @Failure 401 {string} string "Unauthorized"
+// @Accept  json
+// @Produce  json
+// @Param   request body models.CloseAccountRequest true "Password confirmation and shop transfers"
+// @Success 200 {string} string "Account closed, with the time it will be deleted"
+// @Failure 400 {string} string "Invalid input" / "Invalid shop transfer"
+// @Failure 401 {string} string "Password is incorrect"
+// @Failure 409 {string} string "Account already closed or orders in progress"
 // @Failure 500 {string} string "Failed to close account"
 // @Router /users/close-account [delete]
-func CloseAccount(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("user").(models.Claims)
+func (h *Handler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.CloseAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
 
 	var user models.User
-	if result := database.DB.Delete(&user, claims.UserID); result.Error != nil {
-		http.Error(w, "Failed to close account.", http.StatusInternalServerError)
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		http.Error(w, "Password is incorrect.", http.StatusUnauthorized)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	h.closeAccount(w, r, user, input.TransferShops, "user.close")
 }
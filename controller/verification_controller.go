@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+const (
+	verificationSubject        = "email-verification"
+	verificationResendInterval = time.Minute
+)
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the email address of a user with the token from the verification email
+// @Tags User
+// @Produce  json
+// @Param   token query string true "Verification token"
+// @Success 200 {string} string "Email verified successfully"
+// @Failure 400 {string} string "Invalid or expired verification link"
+// @Failure 500 {string} string "Failed to verify email"
+// @Router /users/verify [get]
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	claims := &models.EmailVerificationClaims{}
+	token, err := jwt.ParseWithClaims(r.URL.Query().Get("token"), claims, func(t *jwt.Token) (interface{}, error) {
+		return h.tokenKey(verificationSubject), nil
+	})
+	if err != nil || !token.Valid || claims.Subject != verificationSubject {
+		http.Error(w, "Invalid or expired verification link.", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil || user.Email != claims.Email {
+		http.Error(w, "Invalid or expired verification link.", http.StatusBadRequest)
+		return
+	}
+
+	if user.EmailVerifiedAt == nil {
+		before := user
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, r, audit.Entry{ActorID: user.ID, Action: "user.verify", EntityType: "user", EntityID: user.ID, Before: before, After: user})
+		})
+		if err != nil {
+			http.Error(w, "Failed to verify email.", http.StatusInternalServerError)
+			return
+		}
+		notification.Notify(database.DB, user.ID, "welcome", nil)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email verified successfully."})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification email to the logged-in user. Limited to one email per minute.
+// @Tags User
+// @Produce  json
+// @Success 200 {string} string "Verification email sent"
+// @Failure 400 {string} string "Email already verified"
+// @Failure 429 {string} string "Please wait before requesting another verification email"
+// @Failure 500 {string} string "Failed to send verification email"
+// @Router /users/verify/resend [post]
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var user models.User
+	if result := database.DB.First(&user, claims.UserID); result.Error != nil {
+		http.Error(w, "User not found.", http.StatusNotFound)
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "Email already verified.", http.StatusBadRequest)
+		return
+	}
+
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendInterval {
+		http.Error(w, "Please wait before requesting another verification email.", http.StatusTooManyRequests)
+		return
+	}
+
+	if err := h.sendVerificationEmail(&user); err != nil {
+		http.Error(w, "Failed to send verification email.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent."})
+}
+
+// sendVerificationEmail enqueues a signed, expiring verification link for the user's current email address.
+func (h *Handler) sendVerificationEmail(user *models.User) error {
+	expiresAt := time.Now().Add(h.cfg.Auth.EmailVerificationTTL)
+	claims := &models.EmailVerificationClaims{
+		UserID: user.ID,
+		Email:  user.Email,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   verificationSubject,
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.tokenKey(verificationSubject))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(user).Update("verification_sent_at", now).Error; err != nil {
+		return err
+	}
+
+	return notification.Enqueue(database.DB, user.ID, "verify_email", map[string]interface{}{
+		"Link":      h.cfg.App.URL + "/users/verify?token=" + url.QueryEscape(tokenStr),
+		"ExpiresAt": expiresAt,
+	})
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"e_commerce/audit"
+	"e_commerce/database"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// GetWishlist godoc
+// @Summary Get my wishlist
+// @Description Get the saved products of the logged-in user
+// @Tags Wishlist
+// @Produce  json
+// @Success 200 {array} models.WishlistItem
+// @Failure 500 {string} string "Failed to retrieve wishlist"
+// @Router /users/profile/wishlist [get]
+func (h *Handler) GetWishlist(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var items []models.WishlistItem
+	if result := database.DB.Preload("Product").Where("user_id = ?", claims.UserID).Order("created_at DESC").Find(&items); result.Error != nil {
+		http.Error(w, "Failed to retrieve wishlist.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+// AddToWishlist godoc
+// @Summary Add a product to my wishlist
+// @Description Save a product to the wishlist of the logged-in user
+// @Tags Wishlist
+// @Accept  json
+// @Produce  json
+// @Param   body body models.WishlistRequest true "Product"
+// @Success 201 {string} string "Product added to wishlist successfully"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 404 {string} string "Product not found"
+// @Failure 500 {string} string "Failed to add product to wishlist"
+// @Router /users/profile/wishlist [post]
+func (h *Handler) AddToWishlist(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+
+	var input models.WishlistRequest
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || input.ProductID == 0 {
+		http.Error(w, "Invalid input.", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	if result := listedProducts(database.DB).First(&product, input.ProductID); result.Error != nil {
+		http.Error(w, "Product not found.", http.StatusNotFound)
+		return
+	}
+
+	item := models.WishlistItem{UserID: claims.UserID, ProductID: product.ID, CreatedAt: time.Now()}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Product").Where(models.WishlistItem{UserID: claims.UserID, ProductID: product.ID}).FirstOrCreate(&item)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "wishlist_item.create", EntityType: "wishlist_item", EntityID: item.ID, After: item})
+	})
+	if err != nil {
+		http.Error(w, "Failed to add product to wishlist.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Product added to wishlist successfully."})
+}
+
+// RemoveFromWishlist godoc
+// @Summary Remove a product from my wishlist
+// @Description Remove a product from the wishlist of the logged-in user
+// @Tags Wishlist
+// @Param   product_id path int true "Product ID"
+// @Success 204 {string} string "Product removed from wishlist successfully"
+// @Failure 400 {string} string "Invalid id"
+// @Failure 500 {string} string "Failed to remove product from wishlist"
+// @Router /users/profile/wishlist/{product_id} [delete]
+func (h *Handler) RemoveFromWishlist(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("user").(*models.Claims)
+	params := mux.Vars(r)
+	productID, err := strconv.Atoi(params["product_id"])
+	if err != nil {
+		http.Error(w, "Invalid id.", http.StatusBadRequest)
+		return
+	}
+
+	var item models.WishlistItem
+	if result := database.DB.Where("user_id = ? AND product_id = ?", claims.UserID, productID).First(&item); result.Error != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&item).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, r, audit.Entry{Action: "wishlist_item.delete", EntityType: "wishlist_item", EntityID: item.ID, Before: item})
+	})
+	if err != nil {
+		http.Error(w, "Failed to remove product from wishlist.", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyWishlistOwners tells the users who saved the product that its price dropped or it is back in stock.
+func notifyWishlistOwners(product models.Product, oldPrice float64, oldStock int) {
+	var template string
+	switch {
+	case oldStock <= 0 && product.Stock > 0:
+		template = "back_in_stock"
+	case product.Price < oldPrice:
+		template = "price_drop"
+	default:
+		return
+	}
+
+	var userIDs []uint
+	database.DB.Model(&models.WishlistItem{}).Where("product_id = ?", product.ID).Pluck("user_id", &userIDs)
+	for _, userID := range userIDs {
+		notification.Notify(database.DB, userID, template, map[string]interface{}{"Product": product, "OldPrice": oldPrice})
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"e_commerce/models"
+	"e_commerce/totp"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// BackfillOrderShops sets the shop of orders placed before orders were split per shop, so sellers can find them.
+// The shop is taken from the products of the order; orders with products of several shops are left as they are.
+func BackfillOrderShops() error {
+	var orders []models.Order
+	if err := DB.Preload("Items").
+		Where("shop_id IS NULL AND parent_id IS NULL").
+		Where("id NOT IN (?)", DB.Model(&models.Order{}).Select("parent_id").Where("parent_id IS NOT NULL")).
+		Find(&orders).Error; err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		var productIDs []uint
+		for _, item := range order.Items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		if len(productIDs) == 0 {
+			continue
+		}
+
+		var shopIDs []uint
+		if err := DB.Unscoped().Model(&models.Product{}).Where("id IN ?", productIDs).Distinct().Pluck("shop_id", &shopIDs).Error; err != nil {
+			return err
+		}
+		if len(shopIDs) != 1 {
+			log.Printf("Order %d has products of %d shops, its shop is not set", order.ID, len(shopIDs))
+			continue
+		}
+
+		if err := DB.Model(&models.Order{}).Where("id = ?", order.ID).Update("shop_id", shopIDs[0]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillEmailVerification marks the users created before email verification existed as verified, so turning
+// the requirement on does not lock them out. They are the unverified users who were never sent a verification email.
+func BackfillEmailVerification() error {
+	return DB.Model(&models.User{}).Where("email_verified_at IS NULL AND verification_sent_at IS NULL").
+		Update("email_verified_at", gorm.Expr("created_at")).Error
+}
+
+// BackfillTOTPSecrets encrypts the 2FA secrets stored before secrets were encrypted.
+func BackfillTOTPSecrets(secrets *totp.Cipher) error {
+	var users []models.User
+	if err := DB.Select("id", "totp_secret").Where("totp_secret <> ? AND totp_secret NOT LIKE ?", "", "enc:%").Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		sealed, err := secrets.Seal(user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		if err := DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_secret", sealed).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillSaleOrders sets the sale order ID of the sales recorded before it existed, so the unique index also
+// covers them. Only the first sale of an order is marked; later duplicates are left for review.
+func BackfillSaleOrders() error {
+	var ids []uint
+	if err := DB.Model(&models.LedgerTransaction{}).Where("type = ?", "sale").
+		Group("order_id").Having("COUNT(sale_order_id) = 0").Pluck("MIN(id)", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&models.LedgerTransaction{}).Where("id IN ?", ids).
+		Update("sale_order_id", gorm.Expr("order_id")).Error
+}
@@ -1,9 +1,9 @@
 package database
 
 import (
+	"e_commerce/config"
 	"e_commerce/models"
 	"log"
-	"os"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -11,15 +11,32 @@ import (
 
 var DB *gorm.DB
 
-func Connect() {
-	dsn := os.Getenv("DSL")
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+func Connect(cfg config.Database) {
+	db, err := gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	DB = db
 }
 
+// Close closes the connection pool.
+func Close() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 func Migrate() {
 	DB.AutoMigrate(&models.User{})
 	DB.AutoMigrate(&models.Shop{})
@@ -27,4 +44,34 @@ func Migrate() {
 	DB.AutoMigrate(&models.Order{})
 	DB.AutoMigrate(&models.OrderItem{})
 	DB.AutoMigrate(&models.RefreshToken{})
+	DB.AutoMigrate(&models.ReturnRequest{})
+	DB.AutoMigrate(&models.ReturnItem{})
+	DB.AutoMigrate(&models.Address{})
+	DB.AutoMigrate(&models.ShippingMethod{})
+	DB.AutoMigrate(&models.ShippingRate{})
+	DB.AutoMigrate(&models.Shipment{})
+	DB.AutoMigrate(&models.ShipmentEvent{})
+	DB.AutoMigrate(&models.LedgerTransaction{})
+	DB.AutoMigrate(&models.LedgerEntry{})
+	DB.AutoMigrate(&models.CommissionRule{})
+	DB.AutoMigrate(&models.PayoutBatch{})
+	DB.AutoMigrate(&models.Payout{})
+	DB.AutoMigrate(&models.Invoice{})
+	DB.AutoMigrate(&models.InvoiceLine{})
+	DB.AutoMigrate(&models.InvoiceSequence{})
+	DB.AutoMigrate(&models.Review{})
+	DB.AutoMigrate(&models.ReviewImage{})
+	DB.AutoMigrate(&models.ShopRating{})
+	DB.AutoMigrate(&models.WishlistItem{})
+	DB.AutoMigrate(&models.Notification{})
+	DB.AutoMigrate(&models.PasswordResetToken{})
+	DB.AutoMigrate(&models.PasswordResetRequest{})
+	DB.AutoMigrate(&models.RecoveryCode{})
+	DB.AutoMigrate(&models.UserIdentity{})
+	DB.AutoMigrate(&models.LoginAttempt{})
+	DB.AutoMigrate(&models.Permission{})
+	DB.AutoMigrate(&models.Role{})
+	DB.AutoMigrate(&models.ShopMember{})
+	DB.AutoMigrate(&models.ShopInvitation{})
+	DB.AutoMigrate(&models.AuditLog{})
 }
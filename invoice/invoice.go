@@ -0,0 +1,201 @@
+package invoice
+
+import (
+	"e_commerce/models"
+	"e_commerce/pdf"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotShopOrder = errors.New("invoices are issued for shop orders only")
+	ErrNotConfirmed = errors.New("invoices are issued once the shop order is confirmed")
+)
+
+// issuedStatuses are the shop order statuses from which an invoice can be issued.
+var issuedStatuses = map[string]bool{"confirmed": true, "shipped": true, "delivered": true, "refunded": true}
+
+// Issue returns the invoice of a shop order, creating it with the shop's next invoice number on first use.
+// The number is taken inside the same transaction that stores the invoice, so numbers have no gaps.
+// A new invoice is only issued once the seller has confirmed the order. The tax rate is the VAT rate included in
+// product prices, in percent.
+func Issue(db *gorm.DB, order models.Order, taxRate float64) (models.Invoice, error) {
+	var inv models.Invoice
+	if order.ShopID == nil {
+		return inv, ErrNotShopOrder
+	}
+
+	if err := db.Preload("Lines").Where("order_id = ?", order.ID).First(&inv).Error; err == nil {
+		return inv, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return inv, err
+	}
+	if !issuedStatuses[order.Status] {
+		return inv, ErrNotConfirmed
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var shop models.Shop
+		if err := tx.Unscoped().First(&shop, *order.ShopID).Error; err != nil {
+			return err
+		}
+
+		var seller, buyer models.User
+		if err := tx.Unscoped().First(&seller, shop.OwnerID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().First(&buyer, order.UserID).Error; err != nil {
+			return err
+		}
+
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return err
+		}
+
+		seq := models.InvoiceSequence{ShopID: shop.ID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "shop_id = ?", shop.ID).Error; err != nil {
+			return err
+		}
+		seq.LastNumber++
+		if err := tx.Save(&seq).Error; err != nil {
+			return err
+		}
+
+		inv = models.Invoice{
+			ShopID:         shop.ID,
+			Sequence:       seq.LastNumber,
+			Number:         fmt.Sprintf("INV-%d-%06d", shop.ID, seq.LastNumber),
+			OrderID:        order.ID,
+			SellerName:     seller.Name + " " + seller.Surname,
+			SellerEmail:    seller.Email,
+			ShopName:       shop.Name,
+			BuyerName:      buyer.Name + " " + buyer.Surname,
+			BuyerEmail:     buyer.Email,
+			BillingAddress: order.BillingAddress,
+			ShippingCost:   order.ShippingCost,
+			Total:          order.TotalAmount,
+			IssuedAt:       time.Now(),
+			CreatedAt:      time.Now(),
+		}
+
+		for _, item := range items {
+			var product models.Product
+			tx.Unscoped().Select("name").First(&product, item.ProductID)
+			inv.Lines = append(inv.Lines, line(product.Name, item.Quantity, item.Price, taxRate))
+		}
+		if order.ShippingCost > 0 {
+			inv.Lines = append(inv.Lines, line("Shipping", 1, order.ShippingCost, taxRate))
+		}
+		for _, l := range inv.Lines {
+			inv.TaxTotal += l.TaxAmount
+			inv.Subtotal += l.Total - l.TaxAmount
+		}
+		inv.TaxTotal = round(inv.TaxTotal)
+		inv.Subtotal = round(inv.Subtotal)
+
+		return tx.Create(&inv).Error
+	})
+	return inv, err
+}
+
+func line(description string, quantity int, unitPrice, rate float64) models.InvoiceLine {
+	total := round(unitPrice * float64(quantity))
+	return models.InvoiceLine{
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		TaxRate:     rate,
+		TaxAmount:   round(total - total/(1+rate/100)),
+		Total:       total,
+	}
+}
+
+// Render adds a page with the invoice to the document.
+func Render(doc *pdf.Document, inv models.Invoice) {
+	p := doc.AddPage()
+	const left, right = 50.0, pdf.PageWidth - 50
+
+	p.Text(left, 70, pdf.Bold, 22, "INVOICE")
+	p.Text(left, 95, pdf.Regular, 10, "Invoice no: "+inv.Number)
+	p.Text(left, 110, pdf.Regular, 10, "Date: "+inv.IssuedAt.Format("2006-01-02"))
+	p.Text(left, 125, pdf.Regular, 10, fmt.Sprintf("Order no: %d", inv.OrderID))
+
+	p.Text(left, 160, pdf.Bold, 11, "Seller")
+	p.Text(left, 175, pdf.Regular, 10, inv.ShopName)
+	p.Text(left, 190, pdf.Regular, 10, inv.SellerName)
+	p.Text(left, 205, pdf.Regular, 10, inv.SellerEmail)
+
+	a := inv.BillingAddress
+	p.Text(310, 160, pdf.Bold, 11, "Bill to")
+	y := 175.0
+	for _, s := range []string{inv.BuyerName, inv.BuyerEmail, a.Line1, a.Line2, a.PostalCode + " " + a.City, a.State, a.Country} {
+		if s == "" || s == " " {
+			continue
+		}
+		p.Text(310, y, pdf.Regular, 10, s)
+		y += 15
+	}
+
+	y = math.Max(y, 220) + 25
+	p.Text(left, y, pdf.Bold, 10, "Description")
+	p.Text(300, y, pdf.Bold, 10, "Qty")
+	p.Text(345, y, pdf.Bold, 10, "Unit price")
+	p.Text(425, y, pdf.Bold, 10, "VAT")
+	p.Text(490, y, pdf.Bold, 10, "Total")
+	p.Line(left, y+6, right, y+6)
+	y += 22
+
+	for _, l := range inv.Lines {
+		p.Text(left, y, pdf.Regular, 10, truncate(l.Description, 45))
+		p.TextRight(325, y, 10, strconv.Itoa(l.Quantity))
+		p.TextRight(405, y, 10, money(l.UnitPrice))
+		p.TextRight(465, y, 10, fmt.Sprintf("%g%%", l.TaxRate))
+		p.TextRight(right, y, 10, money(l.Total))
+		y += 16
+		if y > pdf.PageHeight-120 {
+			p = doc.AddPage()
+			y = 70
+		}
+	}
+
+	p.Line(left, y-6, right, y-6)
+	y += 12
+	for _, t := range []struct {
+		label  string
+		amount float64
+	}{{"Subtotal", inv.Subtotal}, {"VAT", inv.TaxTotal}, {"Total", inv.Total}} {
+		font := pdf.Regular
+		if t.label == "Total" {
+			font = pdf.Bold
+		}
+		p.Text(380, y, font, 10, t.label)
+		p.TextRight(right, y, 10, money(t.amount))
+		y += 16
+	}
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
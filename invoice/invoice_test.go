@@ -0,0 +1,173 @@
+package invoice
+
+import (
+	"e_commerce/models"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.User{}, &models.Shop{}, &models.Product{}, &models.Order{}, &models.OrderItem{},
+		&models.Invoice{}, &models.InvoiceLine{}, &models.InvoiceSequence{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// seedShop creates a shop with its owner and a product, and returns the shop and the product.
+func seedShop(t *testing.T, db *gorm.DB, name string) (models.Shop, models.Product) {
+	t.Helper()
+	owner := models.User{Name: "Ada", Surname: "Seller", Email: name + "@example.com", Password: "x", Role: "seller"}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	shop := models.Shop{Name: name, OwnerID: owner.ID}
+	if err := db.Create(&shop).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{Name: "Lamp", Description: "Desk lamp", Price: 120, Stock: 10, ShopID: shop.ID, Category: "home"}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	return shop, product
+}
+
+func createOrder(t *testing.T, db *gorm.DB, shop models.Shop, product models.Product, status string) models.Order {
+	t.Helper()
+	var buyer models.User
+	if err := db.Where(models.User{Email: "buyer@example.com"}).
+		Attrs(models.User{Name: "Grace", Surname: "Buyer", Password: "x", Role: "customer"}).
+		FirstOrCreate(&buyer).Error; err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{
+		UserID:       buyer.ID,
+		ShopID:       &shop.ID,
+		TotalAmount:  2*product.Price + 10,
+		ShippingCost: 10,
+		Status:       status,
+		Items:        []models.OrderItem{{ProductID: product.ID, Quantity: 2, Price: product.Price, Total: 2 * product.Price}},
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestIssueNumbersEachShopWithoutGaps(t *testing.T) {
+	db := openDB(t)
+	shopA, productA := seedShop(t, db, "shop-a")
+	shopB, productB := seedShop(t, db, "shop-b")
+
+	orders := []struct {
+		shop    models.Shop
+		product models.Product
+		want    uint
+	}{
+		{shopA, productA, 1},
+		{shopA, productA, 2},
+		{shopB, productB, 1},
+		{shopA, productA, 3},
+		{shopB, productB, 2},
+	}
+	for i, o := range orders {
+		inv, err := Issue(db, createOrder(t, db, o.shop, o.product, "confirmed"), 0)
+		if err != nil {
+			t.Fatalf("order %d: Issue: %v", i, err)
+		}
+		if inv.Sequence != o.want {
+			t.Errorf("order %d: sequence = %d, want %d", i, inv.Sequence, o.want)
+		}
+		if want := fmt.Sprintf("INV-%d-%06d", o.shop.ID, o.want); inv.Number != want {
+			t.Errorf("order %d: number = %q, want %q", i, inv.Number, want)
+		}
+	}
+}
+
+func TestIssueReturnsTheExistingInvoice(t *testing.T) {
+	db := openDB(t)
+	shop, product := seedShop(t, db, "shop")
+	order := createOrder(t, db, shop, product, "delivered")
+
+	first, err := Issue(db, order, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Issue(db, order, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID || second.Number != first.Number {
+		t.Errorf("second Issue = %d %s, want %d %s", second.ID, second.Number, first.ID, first.Number)
+	}
+	if len(second.Lines) != len(first.Lines) {
+		t.Errorf("second Issue has %d lines, want %d", len(second.Lines), len(first.Lines))
+	}
+
+	var count int64
+	db.Model(&models.Invoice{}).Where("order_id = ?", order.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("%d invoices stored for the order, want 1", count)
+	}
+	var seq models.InvoiceSequence
+	db.First(&seq, "shop_id = ?", shop.ID)
+	if seq.LastNumber != 1 {
+		t.Errorf("last number = %d, want 1", seq.LastNumber)
+	}
+}
+
+func TestIssueRefusesOrdersThatAreNotInvoiceable(t *testing.T) {
+	db := openDB(t)
+	shop, product := seedShop(t, db, "shop")
+
+	for _, status := range []string{"pending", "cancelled"} {
+		if _, err := Issue(db, createOrder(t, db, shop, product, status), 0); !errors.Is(err, ErrNotConfirmed) {
+			t.Errorf("%s order: Issue error = %v, want ErrNotConfirmed", status, err)
+		}
+	}
+
+	parent := models.Order{UserID: 1, Status: "confirmed"}
+	if _, err := Issue(db, parent, 0); !errors.Is(err, ErrNotShopOrder) {
+		t.Errorf("parent order: Issue error = %v, want ErrNotShopOrder", err)
+	}
+
+	// Refused orders do not take a number, so the next invoice is still the first.
+	inv, err := Issue(db, createOrder(t, db, shop, product, "shipped"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Sequence != 1 {
+		t.Errorf("sequence after refused orders = %d, want 1", inv.Sequence)
+	}
+}
+
+func TestIssueTotals(t *testing.T) {
+	db := openDB(t)
+	shop, product := seedShop(t, db, "shop")
+
+	inv, err := Issue(db, createOrder(t, db, shop, product, "confirmed"), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Lines) != 2 {
+		t.Fatalf("%d lines, want the product and shipping", len(inv.Lines))
+	}
+	if inv.Total != 250 || inv.Subtotal+inv.TaxTotal != inv.Total {
+		t.Errorf("total %.2f = subtotal %.2f + tax %.2f, want 250", inv.Total, inv.Subtotal, inv.TaxTotal)
+	}
+	if inv.TaxTotal != 41.67 {
+		t.Errorf("tax = %.2f, want 41.67", inv.TaxTotal)
+	}
+}
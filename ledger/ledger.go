@@ -0,0 +1,355 @@
+package ledger
+
+import (
+	"context"
+	"e_commerce/models"
+	"e_commerce/payment"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	CashAccount       = "platform:cash"
+	CommissionAccount = "platform:commission"
+)
+
+// ShopAccount is the account holding what the platform owes to a shop.
+func ShopAccount(shopID uint) string {
+	return fmt.Sprintf("shop:%d:payable", shopID)
+}
+
+// CommissionRate returns the most specific commission rate for a shop and category:
+// shop and category, then shop, then category, then the default rate. Rates are in percent.
+func CommissionRate(db *gorm.DB, shopID uint, category string, defaultRate float64) (float64, error) {
+	var rules []models.CommissionRule
+	if err := db.Where("(shop_id = ? OR shop_id IS NULL) AND (category = ? OR category = '')", shopID, category).
+		Find(&rules).Error; err != nil {
+		return 0, err
+	}
+
+	best, bestScore := defaultRate, -1
+	for _, rule := range rules {
+		score := 0
+		if rule.ShopID != nil {
+			score += 2
+		}
+		if rule.Category != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rule.Rate, score
+		}
+	}
+	return best, nil
+}
+
+// RecordSale credits a delivered shop order to the shop and takes the platform commission on its items.
+// It does nothing if the order was already recorded; the unique sale order ID stops a concurrent second sale.
+// The default rate applies to items no commission rule matches.
+func RecordSale(tx *gorm.DB, order models.Order, defaultRate float64) error {
+	if order.ShopID == nil {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.LedgerTransaction{}).Where("order_id = ? AND type = ?", order.ID, "sale").Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	commission := 0.0
+	for _, item := range items {
+		c, err := itemCommission(tx, *order.ShopID, item.ProductID, item.Total, defaultRate)
+		if err != nil {
+			return err
+		}
+		commission += c
+	}
+
+	shopAccount := ShopAccount(*order.ShopID)
+	err := post(tx, models.LedgerTransaction{
+		Type:        "sale",
+		ShopID:      *order.ShopID,
+		OrderID:     &order.ID,
+		SaleOrderID: &order.ID,
+		Description: fmt.Sprintf("Sale of order #%d", order.ID),
+	}, entry(CashAccount, order.TotalAmount, 0), entry(shopAccount, 0, order.TotalAmount))
+	if err != nil {
+		return err
+	}
+
+	if commission == 0 {
+		return nil
+	}
+	return post(tx, models.LedgerTransaction{
+		Type:        "commission",
+		ShopID:      *order.ShopID,
+		OrderID:     &order.ID,
+		Description: fmt.Sprintf("Platform commission for order #%d", order.ID),
+	}, entry(shopAccount, commission, 0), entry(CommissionAccount, 0, commission))
+}
+
+// RecordRefund charges a refunded return to the shop and gives back the commission taken on the returned items.
+func RecordRefund(tx *gorm.DB, order models.Order, ret models.ReturnRequest, defaultRate float64) error {
+	commission := 0.0
+	for _, item := range ret.Items {
+		c, err := itemCommission(tx, ret.ShopID, item.ProductID, item.Amount, defaultRate)
+		if err != nil {
+			return err
+		}
+		commission += c
+	}
+
+	shopAccount := ShopAccount(ret.ShopID)
+	entries := []models.LedgerEntry{
+		entry(shopAccount, ret.RefundAmount, 0),
+		entry(CashAccount, 0, ret.RefundAmount),
+	}
+	if commission > 0 {
+		entries = append(entries, entry(CommissionAccount, commission, 0), entry(shopAccount, 0, commission))
+	}
+
+	return post(tx, models.LedgerTransaction{
+		Type:        "refund",
+		ShopID:      ret.ShopID,
+		OrderID:     &order.ID,
+		Description: fmt.Sprintf("Refund of return #%d", ret.ID),
+	}, entries...)
+}
+
+// Balance returns what the platform currently owes to the shop.
+func Balance(db *gorm.DB, shopID uint) (float64, error) {
+	var balance float64
+	err := db.Model(&models.LedgerEntry{}).Where("account = ?", ShopAccount(shopID)).
+		Select("COALESCE(SUM(credit - debit), 0)").Scan(&balance).Error
+	return round(balance), err
+}
+
+// Statement returns the movements of the shop's account between from and to, newest first.
+func Statement(db *gorm.DB, shopID uint, from, to time.Time) ([]models.StatementLine, error) {
+	var lines []models.StatementLine
+	err := db.Table("ledger_entries").
+		Select("ledger_transactions.id AS transaction_id, ledger_transactions.type, ledger_transactions.order_id, "+
+			"ledger_transactions.payout_id, ledger_transactions.description, "+
+			"ledger_entries.credit - ledger_entries.debit AS amount, ledger_entries.created_at").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+		Where("ledger_entries.account = ? AND ledger_entries.created_at BETWEEN ? AND ?", ShopAccount(shopID), from, to).
+		Order("ledger_entries.created_at DESC, ledger_entries.id DESC").
+		Scan(&lines).Error
+	return lines, err
+}
+
+// RunPayouts pays out every shop whose balance reaches minAmount and groups the payouts in a settlement batch.
+// Payouts left pending by an interrupted run are sent again first with their original idempotency key.
+func RunPayouts(db *gorm.DB, minAmount float64) (models.PayoutBatch, error) {
+	batch := models.PayoutBatch{Status: "processing", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(&batch).Error; err != nil {
+		return batch, err
+	}
+
+	var pending []models.Payout
+	if err := db.Where("status = ?", "pending").Find(&pending).Error; err != nil {
+		return batch, err
+	}
+	for i := range pending {
+		if err := settlePayout(db, &pending[i]); err != nil {
+			log.Printf("Failed to settle payout %d for shop %d: %v", pending[i].ID, pending[i].ShopID, err)
+		}
+	}
+
+	var shopIDs []uint
+	if err := db.Model(&models.Shop{}).Unscoped().Pluck("id", &shopIDs).Error; err != nil {
+		return batch, err
+	}
+
+	failed := false
+	for _, shopID := range shopIDs {
+		payout, err := reservePayout(db, batch.ID, shopID, minAmount)
+		if err != nil {
+			log.Printf("Failed to reserve payout for shop %d: %v", shopID, err)
+			failed = true
+			continue
+		}
+		if payout == nil {
+			continue
+		}
+
+		if err := settlePayout(db, payout); err != nil {
+			log.Printf("Failed to settle payout %d for shop %d: %v", payout.ID, shopID, err)
+			failed = true
+			continue
+		}
+		if payout.Status != "paid" {
+			failed = true
+			continue
+		}
+
+		batch.Total += payout.Amount
+		batch.Payouts = append(batch.Payouts, *payout)
+	}
+
+	batch.Status = "completed"
+	if failed {
+		batch.Status = "failed"
+	}
+	batch.Total = round(batch.Total)
+	batch.UpdatedAt = time.Now()
+	return batch, db.Omit("Payouts").Save(&batch).Error
+}
+
+// reservePayout moves the shop's balance into a pending payout before any money is sent. The shop row is locked
+// so a scheduled and a manual run cannot pay the same balance twice. It returns nil when there is nothing to pay.
+func reservePayout(db *gorm.DB, batchID, shopID uint, minAmount float64) (*models.Payout, error) {
+	var payout *models.Payout
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Shop{}, shopID).Error; err != nil {
+			return err
+		}
+
+		balance, err := Balance(tx, shopID)
+		if err != nil {
+			return err
+		}
+		if balance <= 0 || balance < minAmount {
+			return nil
+		}
+
+		p := models.Payout{
+			BatchID:        batchID,
+			ShopID:         shopID,
+			Amount:         balance,
+			Status:         "pending",
+			IdempotencyKey: fmt.Sprintf("payout-%d-%d", batchID, shopID),
+			CreatedAt:      time.Now(),
+			UpdatedAt:      time.Now(),
+		}
+		if err := tx.Create(&p).Error; err != nil {
+			return err
+		}
+		if err := post(tx, models.LedgerTransaction{
+			Type:        "payout",
+			ShopID:      shopID,
+			PayoutID:    &p.ID,
+			Description: fmt.Sprintf("Payout #%d", p.ID),
+		}, entry(ShopAccount(shopID), balance, 0), entry(CashAccount, 0, balance)); err != nil {
+			return err
+		}
+		payout = &p
+		return nil
+	})
+	return payout, err
+}
+
+// settlePayout sends a pending payout to the payment provider and records the result. A failed payout gives
+// the amount back to the shop's balance so the next run pays it again.
+func settlePayout(db *gorm.DB, payout *models.Payout) error {
+	ref, payErr := payment.Default.Payout(payout.IdempotencyKey, payout.ShopID, payout.Amount)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": "paid", "reference": ref, "updated_at": time.Now()}
+		if payErr != nil {
+			updates = map[string]interface{}{"status": "failed", "error": payErr.Error(), "updated_at": time.Now()}
+		}
+
+		// Aynı bekleyen ödemeyi başka bir çalıştırma sonuçlandırdıysa tekrar kaydedilmez
+		result := tx.Model(&models.Payout{}).Where("id = ? AND status = ?", payout.ID, "pending").Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return tx.First(payout, payout.ID).Error
+		}
+
+		if payErr != nil {
+			payout.Status = "failed"
+			payout.Error = payErr.Error()
+			return post(tx, models.LedgerTransaction{
+				Type:        "payout_reversal",
+				ShopID:      payout.ShopID,
+				PayoutID:    &payout.ID,
+				Description: fmt.Sprintf("Failed payout #%d", payout.ID),
+			}, entry(CashAccount, payout.Amount, 0), entry(ShopAccount(payout.ShopID), 0, payout.Amount))
+		}
+		payout.Status = "paid"
+		payout.Reference = ref
+		return nil
+	})
+}
+
+// StartPayoutScheduler runs RunPayouts every interval until ctx is cancelled. wg is done once a batch that
+// is running when ctx is cancelled has finished.
+func StartPayoutScheduler(ctx context.Context, wg *sync.WaitGroup, db *gorm.DB, interval time.Duration, minAmount float64) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			batch, err := RunPayouts(db, minAmount)
+			if err != nil {
+				log.Println("Payout batch failed:", err)
+				continue
+			}
+			log.Printf("Payout batch %d %s: %d payouts, %.2f total", batch.ID, batch.Status, len(batch.Payouts), batch.Total)
+		}
+	}()
+}
+
+func itemCommission(tx *gorm.DB, shopID, productID uint, amount, defaultRate float64) (float64, error) {
+	var product models.Product
+	if err := tx.Unscoped().Select("category").First(&product, productID).Error; err != nil {
+		return 0, err
+	}
+	rate, err := CommissionRate(tx, shopID, product.Category, defaultRate)
+	if err != nil {
+		return 0, err
+	}
+	return round(amount * rate / 100), nil
+}
+
+func entry(account string, debit, credit float64) models.LedgerEntry {
+	return models.LedgerEntry{Account: account, Debit: round(debit), Credit: round(credit)}
+}
+
+// post saves a transaction after checking that its entries balance.
+func post(tx *gorm.DB, t models.LedgerTransaction, entries ...models.LedgerEntry) error {
+	var debit, credit float64
+	for _, e := range entries {
+		debit += e.Debit
+		credit += e.Credit
+	}
+	if round(debit) != round(credit) {
+		return fmt.Errorf("unbalanced ledger transaction %q: debit %.2f, credit %.2f", t.Type, debit, credit)
+	}
+
+	now := time.Now()
+	t.CreatedAt = now
+	for i := range entries {
+		entries[i].CreatedAt = now
+	}
+	t.Entries = entries
+	return tx.Create(&t).Error
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
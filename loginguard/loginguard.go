@@ -0,0 +1,189 @@
+package loginguard
+
+import (
+	"e_commerce/config"
+	"e_commerce/models"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attempt reasons stored in the audit table.
+const (
+	ReasonPassword        = "password"
+	ReasonMFA             = "mfa"
+	ReasonInvalidPassword = "invalid_password"
+	ReasonInvalidMFACode  = "invalid_mfa_code"
+	ReasonUnknownUser     = "unknown_user"
+	ReasonThrottled       = "throttled"
+	ReasonLocked          = "locked"
+)
+
+// Policy controls how failed logins are slowed down and when an account is locked.
+type Policy struct {
+	FreeAttempts     int           // Hesap başına gecikmesiz hatalı deneme sayısı
+	LockoutThreshold int           // Hesabı kilitleyen ardışık hatalı deneme sayısı
+	LockoutDuration  time.Duration // Kilidin süresi
+	IPFreeAttempts   int           // IPWindow içinde IP başına gecikmesiz hatalı deneme sayısı
+	IPWindow         time.Duration
+	BaseDelay        time.Duration // İlk gecikme, her hatalı denemede iki katına çıkar
+	MaxDelay         time.Duration
+}
+
+// NewPolicy returns the policy with the configured lockout settings.
+func NewPolicy(cfg config.Login) Policy {
+	return Policy{
+		FreeAttempts:     3,
+		LockoutThreshold: cfg.LockoutThreshold,
+		LockoutDuration:  cfg.LockoutDuration,
+		IPFreeAttempts:   cfg.IPFreeAttempts,
+		IPWindow:         cfg.IPWindow,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+	}
+}
+
+// ProxyHops returns how many proxies in front of the server add themselves to X-Forwarded-For, or 0 when the
+// header is not trusted.
+func ProxyHops(cfg config.Server) int {
+	if !cfg.TrustProxy {
+		return 0
+	}
+	return cfg.ProxyHops
+}
+
+// Wait returns how long the client has to wait before it may try to log in again, and whether that is
+// because the account is locked. user is nil when the email does not belong to an account.
+func (p Policy) Wait(db *gorm.DB, user *models.User, ip string, now time.Time) (time.Duration, bool) {
+	var wait time.Duration
+
+	if user != nil {
+		if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+			return user.LockedUntil.Sub(now), true
+		}
+		if user.FailedLoginCount > p.FreeAttempts && user.LastFailedLoginAt != nil {
+			wait = user.LastFailedLoginAt.Add(p.delay(user.FailedLoginCount - p.FreeAttempts)).Sub(now)
+		}
+	}
+
+	// Throttled attempts count as failures here too, so a client that keeps hammering stays blocked.
+	var ipFailures struct {
+		Count int64
+		Last  *time.Time
+	}
+	db.Model(&models.LoginAttempt{}).Select("COUNT(*) AS count, MAX(created_at) AS last").
+		Where("ip = ? AND success = ? AND created_at > ?", ip, false, now.Add(-p.IPWindow)).Scan(&ipFailures)
+	if int(ipFailures.Count) > p.IPFreeAttempts && ipFailures.Last != nil {
+		if ipWait := ipFailures.Last.Add(p.delay(int(ipFailures.Count) - p.IPFreeAttempts)).Sub(now); ipWait > wait {
+			wait = ipWait
+		}
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, false
+}
+
+// Fail records a failed attempt and locks the account when it reaches the threshold. It reports whether the account was locked now.
+func (p Policy) Fail(db *gorm.DB, user *models.User, email, ip, reason string, now time.Time) (bool, error) {
+	var userID *uint
+	if user != nil {
+		userID = &user.ID
+	}
+	if err := Record(db, userID, email, ip, false, reason, now); err != nil || user == nil {
+		return false, err
+	}
+
+	if err := db.Model(user).Updates(map[string]interface{}{
+		"failed_login_count":   gorm.Expr("failed_login_count + 1"),
+		"last_failed_login_at": now,
+	}).Error; err != nil {
+		return false, err
+	}
+	if err := db.Select("failed_login_count").First(user, user.ID).Error; err != nil {
+		return false, err
+	}
+	if user.FailedLoginCount < p.LockoutThreshold {
+		return false, nil
+	}
+
+	lockedUntil := now.Add(p.LockoutDuration)
+	if err := db.Model(user).Updates(map[string]interface{}{"locked_until": lockedUntil, "failed_login_count": 0}).Error; err != nil {
+		return false, err
+	}
+	user.LockedUntil = &lockedUntil
+	return true, nil
+}
+
+// Succeed records a successful login and clears the failed attempts of the account.
+func Succeed(db *gorm.DB, user *models.User, ip, reason string, now time.Time) error {
+	if err := Record(db, &user.ID, user.Email, ip, true, reason, now); err != nil {
+		return err
+	}
+	return db.Model(user).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+		"last_login_at":      now,
+	}).Error
+}
+
+// Unlock lifts the lockout of an account.
+func Unlock(db *gorm.DB, userID uint) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+	}).Error
+}
+
+func Record(db *gorm.DB, userID *uint, email, ip string, success bool, reason string, now time.Time) error {
+	return db.Create(&models.LoginAttempt{
+		UserID:    userID,
+		Email:     email,
+		IP:        ip,
+		Success:   success,
+		Reason:    reason,
+		CreatedAt: now,
+	}).Error
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is used only when proxyHops (see ProxyHops) is not 0.
+// Each proxy appends the address it received the request from, so the client is the entry added by the
+// outermost trusted proxy, counted from the right; entries further left are sent by the client and can be forged.
+func ClientIP(r *http.Request, proxyHops int) string {
+	if proxyHops > 0 {
+		var hops []string
+		for _, h := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(h, ",")...)
+		}
+		if len(hops) > 0 {
+			i := len(hops) - proxyHops
+			if i < 0 {
+				i = 0
+			}
+			if ip := strings.TrimSpace(hops[i]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (p Policy) delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
@@ -0,0 +1,34 @@
+package loginguard
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name string
+		hops int
+		xff  []string
+		want string
+	}{
+		{"proxy not trusted", 0, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"no header", 1, nil, "192.0.2.1"},
+		{"one proxy", 1, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"forged entry before the proxy's", 1, []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", 2, []string{"10.0.0.1, 203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"repeated headers", 1, []string{"10.0.0.1", "203.0.113.7"}, "203.0.113.7"},
+		{"fewer entries than proxies", 3, []string{"203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/users/login", nil)
+		r.RemoteAddr = "192.0.2.1:51234"
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := ClientIP(r, tt.hops); got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
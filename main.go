@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
+	"e_commerce/config"
+	"e_commerce/controller"
 	"e_commerce/database"
+	"e_commerce/ledger"
+	"e_commerce/middleware"
+	"e_commerce/notification"
+	"e_commerce/privacy"
+	"e_commerce/rbac"
 	"e_commerce/routes"
+	"e_commerce/server"
+	"e_commerce/totp"
+	"flag"
 	"log"
-	"net/http"
+	"sync"
 
-	"github.com/joho/godotenv"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
@@ -27,19 +37,73 @@ import (
 
 // @securityDefinitions.basic BasicAuth
 func main() {
-	err := godotenv.Load()
+	configFile := flag.String("config", "", "YAML configuration file, CONFIG_FILE when empty")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatal("Error loading .env file.")
+		log.Fatal(err)
 	}
 
-	database.Connect()
+	secrets, err := totp.NewCipher(cfg.Auth)
+	if err != nil {
+		log.Fatal("Invalid TOTP encryption key: ", err)
+	}
+
+	database.Connect(cfg.Database)
 	database.Migrate()
+	if err := database.BackfillOrderShops(); err != nil {
+		log.Fatal("Failed to set the shop of orders: ", err)
+	}
+	if err := database.BackfillEmailVerification(); err != nil {
+		log.Fatal("Failed to mark existing users as verified: ", err)
+	}
+	if err := database.BackfillSaleOrders(); err != nil {
+		log.Fatal("Failed to mark recorded sales: ", err)
+	}
+	if err := database.BackfillTOTPSecrets(secrets); err != nil {
+		log.Fatal("Failed to encrypt 2FA secrets: ", err)
+	}
+	if err := notification.ScrubSecrets(database.DB); err != nil {
+		log.Fatal("Failed to blank delivered links: ", err)
+	}
+	if err := rbac.Seed(database.DB); err != nil {
+		log.Fatal("Failed to seed roles: ", err)
+	}
+	if err := rbac.GrantAccountClose(database.DB); err != nil {
+		log.Fatal("Failed to grant account closing: ", err)
+	}
+	if err := rbac.BackfillShopOwners(database.DB); err != nil {
+		log.Fatal("Failed to add shop owners: ", err)
+	}
+
+	// The background jobs are stopped on shutdown and waited for, so none of them is using the database when it is closed.
+	jobs, stopJobs := context.WithCancel(context.Background())
+	var running sync.WaitGroup
+	ledger.StartPayoutScheduler(jobs, &running, database.DB, cfg.Jobs.PayoutInterval, cfg.Jobs.PayoutMinAmount)
+	privacy.StartPurgeScheduler(jobs, &running, database.DB, cfg.Jobs.AccountPurgeInterval)
+	notification.StartWorker(jobs, &running, database.DB, notification.NewNotifier(cfg.Mail), cfg.Jobs.NotificationInterval)
 
-	r := routes.InitRoutes()
+	handler := controller.New(cfg, secrets)
+	r := routes.InitRoutes(handler, middleware.New(cfg))
 
 	// Swagger route
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-	log.Println("Server started on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	srv, err := server.New(cfg.Server, r)
+	if err != nil {
+		log.Fatal("Failed to load TLS certificate: ", err)
+	}
+	srv.OnShutdown(func() error {
+		stopJobs()
+		running.Wait()
+		return nil
+	})
+	srv.OnShutdown(handler.Wait)
+	srv.OnShutdown(database.Close)
+
+	if err := srv.Run(); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Server stopped")
 }
@@ -2,17 +2,16 @@ package middleware
 
 import (
 	"context"
+	"e_commerce/database"
 	"e_commerce/models"
+	"e_commerce/rbac"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_KEY"))
-
-func JWTAuth(next http.Handler) http.Handler {
+func (m *Middleware) JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -23,7 +22,7 @@ func JWTAuth(next http.Handler) http.Handler {
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 		claims := &models.Claims{}
 		token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
+			return m.jwtKey, nil
 		})
 
 		if err != nil || !token.Valid {
@@ -31,24 +30,41 @@ func JWTAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		var user models.User
+		if result := database.DB.Select("id", "role", "tokens_revoked_at", "totp_enabled", "suspended_at", "closed_at").First(&user, claims.UserID); result.Error != nil ||
+			(user.TokensRevokedAt != nil && claims.IssuedAt < user.TokensRevokedAt.Unix()) {
+			http.Error(w, "Invalid token.", http.StatusUnauthorized)
+			return
+		}
+
+		if user.SuspendedAt != nil {
+			http.Error(w, "Your account is suspended.", http.StatusForbidden)
+			return
+		}
+		if user.ClosedAt != nil {
+			http.Error(w, "Your account is closed.", http.StatusForbidden)
+			return
+		}
+
+		// Users whose role requires 2FA can only reach the enrollment endpoints until they enable it.
+		if MFARequired(m.features, user.Role) && !user.TOTPEnabled && !strings.HasPrefix(r.URL.Path, "/users/2fa/") {
+			http.Error(w, "Two-factor authentication must be enabled for your account.", http.StatusForbidden)
+			return
+		}
+
+		// The role is read from the database so that role changes apply to tokens issued before them.
+		claims.Role = user.Role
 		ctx := context.WithValue(r.Context(), "user", claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func Authorize(roles ...string) func(http.Handler) http.Handler {
+// RequirePermission allows the request only when the user's role has the permission.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := r.Context().Value("user").(*models.Claims)
-			authorized := false
-			for _, role := range roles {
-				if user.Role == role {
-					authorized = true
-					break
-				}
-			}
-
-			if !authorized {
+			if !rbac.Has(database.DB, user.Role, permission) {
 				http.Error(w, "Forbidden.", http.StatusForbidden)
 				return
 			}
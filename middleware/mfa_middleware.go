@@ -0,0 +1,14 @@
+package middleware
+
+import "e_commerce/config"
+
+// MFARequired reports whether users with the role must enable two-factor authentication.
+// The roles are configured with MFA_REQUIRED_ROLES; by default no role requires it.
+func MFARequired(features config.Features, role string) bool {
+	for _, r := range features.MFARequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import "e_commerce/config"
+
+// Middleware holds the settings the authentication middleware checks requests against.
+type Middleware struct {
+	jwtKey   []byte
+	features config.Features
+}
+
+// New returns the middleware for the configuration.
+func New(cfg *config.Config) *Middleware {
+	return &Middleware{
+		jwtKey:   []byte(cfg.Auth.JWTKey),
+		features: cfg.Features,
+	}
+}
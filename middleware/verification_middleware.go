@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"e_commerce/database"
+	"e_commerce/models"
+	"net/http"
+)
+
+// RequireVerifiedEmail blocks users who have not verified their email address.
+// The check is skipped when the email verification feature is turned off.
+func (m *Middleware) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.features.RequireEmailVerification {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims := r.Context().Value("user").(*models.Claims)
+
+		var user models.User
+		if result := database.DB.Select("email_verified_at").First(&user, claims.UserID); result.Error != nil || user.EmailVerifiedAt == nil {
+			http.Error(w, "Email address is not verified.", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
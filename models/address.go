@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Address struct {
+	ID                uint   `gorm:"primaryKey"`
+	UserID            uint   `gorm:"not null;index"`
+	Title             string `gorm:"not null"` // Ev, iş vb.
+	FullName          string `gorm:"not null"`
+	Phone             string `gorm:"not null"`
+	Line1             string `gorm:"not null"`
+	Line2             string
+	City              string `gorm:"not null"`
+	State             string
+	PostalCode        string `gorm:"not null"`
+	Country           string `gorm:"not null"`
+	IsDefaultShipping bool   `gorm:"not null;default:false"`
+	IsDefaultBilling  bool   `gorm:"not null;default:false"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+// UpdateAddressRequest is the body of an address update. The default flags are pointers so that an omitted flag
+// keeps its value and false clears it; the outer fields take the JSON keys of the embedded Address.
+type UpdateAddressRequest struct {
+	Address
+	IsDefaultShipping *bool
+	IsDefaultBilling  *bool
+}
+
+// AddressSnapshot is the copy of an address stored on an order, so editing the address later does not change the order.
+type AddressSnapshot struct {
+	FullName   string
+	Phone      string
+	Line1      string
+	Line2      string
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+func (a Address) Snapshot() AddressSnapshot {
+	return AddressSnapshot{
+		FullName:   a.FullName,
+		Phone:      a.Phone,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
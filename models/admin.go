@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Page is one page of an admin listing.
+type Page struct {
+	Items   interface{} `json:"items"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Total   int64       `json:"total"`
+}
+
+// UserSummary is a user as listed in the admin console, without credentials.
+type UserSummary struct {
+	ID               uint
+	Name             string
+	Surname          string
+	Email            string
+	Role             string
+	Locale           string
+	EmailVerifiedAt  *time.Time
+	TOTPEnabled      bool
+	LockedUntil      *time.Time
+	LastLoginAt      *time.Time
+	SuspendedAt      *time.Time
+	SuspensionReason string
+	CreatedAt        time.Time
+}
+
+type SuspendRequest struct {
+	Reason string `json:"reason" example:"Fraudulent orders"`
+}
+
+type ShopStatusRequest struct {
+	Status string `json:"status" example:"suspended"`
+	Reason string `json:"reason" example:"Counterfeit products"`
+}
+
+type AdminOrderStatusRequest struct {
+	Status string `json:"status" example:"cancelled"`
+	Reason string `json:"reason" example:"Customer complaint"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errAuditLogAppendOnly = errors.New("audit log entries cannot be changed or deleted")
+
+// AuditLog is an append-only record of an action taken by a user.
+type AuditLog struct {
+	ID         uint            `gorm:"primaryKey"`
+	ActorID    uint            `gorm:"not null;index"`          // Sistem işlemleri için 0
+	Action     string          `gorm:"not null;size:100;index"` // user.suspend, shop.status, product.unpublish, ...
+	EntityType string          `gorm:"not null;size:50;index:idx_audit_entity"`
+	EntityID   uint            `gorm:"not null;index:idx_audit_entity"`
+	Changes    json.RawMessage `gorm:"type:text"` // Değişen alanların önceki ve sonraki değerleri
+	Reason     string          `gorm:"type:text"`
+	RequestID  string          `gorm:"size:64;index"`
+	CreatedAt  time.Time       `gorm:"index"`
+}
+
+// BeforeUpdate and BeforeDelete keep the audit log append-only.
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return errAuditLogAppendOnly
+}
+
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return errAuditLogAppendOnly
+}
@@ -8,3 +8,32 @@ type Claims struct {
 	Role   string `json:"role"`
 	jwt.StandardClaims
 }
+
+// EmailVerificationClaims is the payload of the signed link sent to confirm an email address.
+type EmailVerificationClaims struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	jwt.StandardClaims
+}
+
+// MFAChallengeClaims is the payload of the short-lived token returned by the first login step when 2FA is enabled.
+type MFAChallengeClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.StandardClaims
+}
+
+// AccountUnlockClaims is the payload of the link emailed when an account is locked. It is valid only for that lockout.
+type AccountUnlockClaims struct {
+	UserID      uint  `json:"user_id"`
+	LockedUntil int64 `json:"locked_until"`
+	jwt.StandardClaims
+}
+
+// OIDCFlowClaims is stored in a cookie between the redirect to an identity provider and its callback.
+type OIDCFlowClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type Invoice struct {
+	ID             uint            `gorm:"primaryKey"`
+	ShopID         uint            `gorm:"not null;uniqueIndex:idx_invoice_shop_sequence"`
+	Sequence       uint            `gorm:"not null;uniqueIndex:idx_invoice_shop_sequence"` // Mağaza bazında boşluksuz sıra
+	Number         string          `gorm:"not null;uniqueIndex"`
+	OrderID        uint            `gorm:"not null;uniqueIndex"` // Faturalanan mağaza siparişi
+	SellerName     string          `gorm:"not null"`
+	SellerEmail    string          `gorm:"not null"`
+	ShopName       string          `gorm:"not null"`
+	BuyerName      string          `gorm:"not null"`
+	BuyerEmail     string          `gorm:"not null"`
+	BillingAddress AddressSnapshot `gorm:"embedded;embeddedPrefix:billing_"`
+	Lines          []InvoiceLine   `gorm:"foreignKey:InvoiceID"`
+	Subtotal       float64         `gorm:"not null"` // Vergiler hariç
+	TaxTotal       float64         `gorm:"not null"`
+	ShippingCost   float64         `gorm:"not null"`
+	Total          float64         `gorm:"not null"`
+	IssuedAt       time.Time       `gorm:"not null"`
+	CreatedAt      time.Time
+}
+
+type InvoiceLine struct {
+	ID          uint    `gorm:"primaryKey"`
+	InvoiceID   uint    `gorm:"not null;index"`
+	Description string  `gorm:"not null"`
+	Quantity    int     `gorm:"not null"`
+	UnitPrice   float64 `gorm:"not null"` // Vergiler dahil
+	TaxRate     float64 `gorm:"not null"` // Yüzde
+	TaxAmount   float64 `gorm:"not null"`
+	Total       float64 `gorm:"not null"` // Vergiler dahil
+}
+
+// InvoiceSequence holds the last invoice number issued by a shop.
+type InvoiceSequence struct {
+	ShopID     uint `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber uint `gorm:"not null;default:0"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// LedgerTransaction groups balanced ledger entries of one business event.
+type LedgerTransaction struct {
+	ID          uint          `gorm:"primaryKey"`
+	Type        string        `gorm:"not null;index"` // sale, commission, refund, payout, payout_reversal
+	ShopID      uint          `gorm:"not null;index"`
+	OrderID     *uint         `gorm:"index"`
+	PayoutID    *uint         `gorm:"index"`
+	SaleOrderID *uint         `gorm:"uniqueIndex" json:"-"` // Yalnızca satışlarda dolu, bir siparişin iki kez satış olarak yazılmasını engeller
+	Description string        `gorm:"not null"`
+	Entries     []LedgerEntry `gorm:"foreignKey:TransactionID"`
+	CreatedAt   time.Time
+}
+
+type LedgerEntry struct {
+	ID            uint    `gorm:"primaryKey"`
+	TransactionID uint    `gorm:"not null;index"`
+	Account       string  `gorm:"not null;index"` // platform:cash, platform:commission, shop:{id}:payable
+	Debit         float64 `gorm:"not null;default:0"`
+	Credit        float64 `gorm:"not null;default:0"`
+	CreatedAt     time.Time
+}
+
+// CommissionRule sets the platform commission for a shop, a category or both. Empty fields match everything.
+type CommissionRule struct {
+	ID        uint    `gorm:"primaryKey"`
+	ShopID    *uint   `gorm:"index"`
+	Category  string  `gorm:"index"`
+	Rate      float64 `gorm:"not null"` // Yüzde, 0-100
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type PayoutBatch struct {
+	ID        uint     `gorm:"primaryKey"`
+	Status    string   `gorm:"not null"` // processing, completed, failed
+	Total     float64  `gorm:"not null;default:0"`
+	Payouts   []Payout `gorm:"foreignKey:BatchID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Payout struct {
+	ID             uint    `gorm:"primaryKey"`
+	BatchID        uint    `gorm:"not null;index"`
+	ShopID         uint    `gorm:"not null;index"`
+	Amount         float64 `gorm:"not null"`
+	Status         string  `gorm:"not null"` // pending, paid, failed
+	IdempotencyKey string  `gorm:"index"`    // Ödeme sağlayıcısına gönderilen tekrar anahtarı
+	Reference      string
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type StatementLine struct {
+	TransactionID uint      `json:"transaction_id"`
+	Type          string    `json:"type"`
+	OrderID       *uint     `json:"order_id"`
+	PayoutID      *uint     `json:"payout_id"`
+	Description   string    `json:"description"`
+	Amount        float64   `json:"amount"` // Mağaza lehine pozitif
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt is the audit record of a single login attempt.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    *uint     `gorm:"index"` // Bilinmeyen e-posta ile yapılan denemelerde boş
+	Email     string    `gorm:"size:255;index"`
+	IP        string    `gorm:"size:45;index"`
+	Success   bool      `gorm:"not null"`
+	Reason    string    `gorm:"size:50"` // password, mfa, invalid_password, throttled, locked, ...
+	CreatedAt time.Time `gorm:"index"`
+}
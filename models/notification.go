@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Notification is a rendered message waiting in the outbox or already delivered.
+type Notification struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"` // Hesabı olmayan alıcılar için 0
+	To        string `gorm:"not null"`
+	Template  string `gorm:"not null"`
+	Subject   string `gorm:"not null"`
+	Text      string `gorm:"type:text;not null"`
+	HTML      string `gorm:"type:text"`
+	Status    string `gorm:"not null;index"` // pending, sending, sent, failed
+	Attempts  int    `gorm:"not null;default:0"`
+	LastError string `gorm:"type:text"`
+	SentAt    *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
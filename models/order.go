@@ -3,10 +3,21 @@ package models
 import "time"
 
 type Order struct {
-	ID          uint    `gorm:"primaryKey"`
-	UserID      uint    `gorm:"not null"` // Siparişi veren kullanıcı
-	TotalAmount float64 `gorm:"not null"` // Toplam tutar
-	Status      string  `gorm:"not null"` // Sipariş durumu: pending, confirmed, shipped, delivered, cancelled
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID               uint            `gorm:"primaryKey"`
+	UserID           uint            `gorm:"not null"`                          // Siparişi veren kullanıcı
+	ParentID         *uint           `gorm:"index"`                             // Mağaza siparişinin bağlı olduğu ana sipariş
+	ShopID           *uint           `gorm:"index"`                             // Mağaza siparişinin mağazası, ana siparişte boş
+	TotalAmount      float64         `gorm:"not null"`                          // Toplam tutar
+	Status           string          `gorm:"not null"`                          // Sipariş durumu: pending, confirmed, shipped, delivered, cancelled, refunded; ana siparişte ayrıca processing
+	ShippingMethodID *uint           `gorm:"index"`                             // Seçilen kargo yöntemi
+	ShippingCost     float64         `gorm:"not null;default:0"`                // Toplam tutara dahil kargo ücreti
+	RefundedAmount   float64         `gorm:"not null;default:0"`                // İade edilen toplam tutar
+	ShippingAddress  AddressSnapshot `gorm:"embedded;embeddedPrefix:shipping_"` // Sipariş anındaki teslimat adresi
+	BillingAddress   AddressSnapshot `gorm:"embedded;embeddedPrefix:billing_"`  // Sipariş anındaki fatura adresi
+	SubOrders        []Order         `gorm:"foreignKey:ParentID"`
+	Items            []OrderItem     `gorm:"foreignKey:OrderID"`
+	Returns          []ReturnRequest `gorm:"foreignKey:OrderID"`
+	Shipments        []Shipment      `gorm:"foreignKey:OrderID"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
@@ -12,3 +12,28 @@ type OrderItem struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+type CreateOrderRequest struct {
+	Quantity          int      `json:"quantity" example:"1"`
+	ShippingAddressID uint     `json:"shipping_address_id" example:"1"`
+	BillingAddressID  uint     `json:"billing_address_id" example:"1"`
+	ShippingMethodID  uint     `json:"shipping_method_id" example:"1"`
+	ShippingAddress   *Address `json:"shipping_address"` // Kayıtlı adres seçilmediğinde kullanılan adres
+}
+
+type CheckoutItem struct {
+	ProductID uint `json:"product_id" example:"1"`
+	Quantity  int  `json:"quantity" example:"1"`
+}
+
+type CheckoutRequest struct {
+	Items             []CheckoutItem `json:"items"`
+	ShippingAddressID uint           `json:"shipping_address_id" example:"1"`
+	BillingAddressID  uint           `json:"billing_address_id" example:"1"`
+	ShippingMethods   map[uint]uint  `json:"shipping_methods"` // mağaza id -> kargo yöntemi id
+	ShippingAddress   *Address       `json:"shipping_address"` // Kayıtlı adres seçilmediğinde kullanılan adres
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status" example:"confirmed"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// PasswordResetToken stores only the SHA-256 hash of a single-use reset token.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex;size:64"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetRequest records a reset request, registered email or not, to rate limit them per email and per IP.
+type PasswordResetRequest struct {
+	ID        uint      `gorm:"primaryKey"`
+	Email     string    `gorm:"size:255;index"`
+	IP        string    `gorm:"size:45;index"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" example:"user@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" example:"3f9c2b..."`
+	NewPassword string `json:"new_password" example:"new_password123"`
+}
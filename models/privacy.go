@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PersonalDataExport is everything the platform stores about a user, as delivered by the data export.
+type PersonalDataExport struct {
+	ExportedAt    time.Time      `json:"exported_at"`
+	Profile       UserSummary    `json:"profile"`
+	Addresses     []Address      `json:"addresses"`
+	Orders        []Order        `json:"orders"`
+	Reviews       []Review       `json:"reviews"`
+	ShopRatings   []ShopRating   `json:"shop_ratings"`
+	Wishlist      []WishlistItem `json:"wishlist"`
+	Identities    []UserIdentity `json:"identities"`
+	ShopMembers   []ShopMember   `json:"shop_memberships"`
+	LoginAttempts []LoginAttempt `json:"login_attempts"`
+	Notifications []Notification `json:"notifications"`
+}
+
+type ErasureRequest struct {
+	Password string `json:"password" example:"password123"`
+}
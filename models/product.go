@@ -15,6 +15,13 @@ type Product struct {
 	Stock       int     `gorm:"not null"`
 	ShopID      uint    `gorm:"not null"`
 	Category    string  `gorm:"not null"`
+	Weight      float64 `gorm:"not null;default:0"` // kg
+	Length      float64 `gorm:"not null;default:0"` // cm
+	Width       float64 `gorm:"not null;default:0"` // cm
+	Height      float64 `gorm:"not null;default:0"` // cm
+	RatingAvg   float64 `gorm:"not null;default:0"` // Yayındaki yorumların ortalama puanı
+	RatingCount int     `gorm:"not null;default:0"`
+	Published   bool    `gorm:"not null;default:true"` // Yönetici yayından kaldırabilir, nedeni denetim kaydında
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type ReturnRequest struct {
+	ID              uint    `gorm:"primaryKey"`
+	OrderID         uint    `gorm:"not null;index"` // İade edilen sipariş
+	UserID          uint    `gorm:"not null;index"` // İadeyi talep eden müşteri
+	ShopID          uint    `gorm:"not null;index"` // İadeyi onaylayacak mağaza
+	Reason          string  `gorm:"type:text;not null"`
+	Status          string  `gorm:"not null"` // requested, approved, rejected, refunded
+	SellerNote      string  `gorm:"type:text"`
+	RefundAmount    float64 `gorm:"not null;default:0"`
+	RefundKey       string  // Ödeme sağlayıcısına gönderilen tekrar anahtarı
+	RefundReference string
+	Items           []ReturnItem `gorm:"foreignKey:ReturnRequestID"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type ReturnItem struct {
+	ID              uint    `gorm:"primaryKey"`
+	ReturnRequestID uint    `gorm:"not null;index"`
+	OrderItemID     uint    `gorm:"not null;index"`
+	ProductID       uint    `gorm:"not null"`
+	Quantity        int     `gorm:"not null"`
+	Amount          float64 `gorm:"not null"` // İade edilecek tutar
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type ReturnItemRequest struct {
+	OrderItemID uint `json:"order_item_id" example:"1"`
+	Quantity    int  `json:"quantity" example:"1"`
+}
+
+type CreateReturnRequest struct {
+	Reason string              `json:"reason" example:"Damaged on arrival"`
+	Items  []ReturnItemRequest `json:"items"`
+}
+
+type ReturnDecisionRequest struct {
+	Note string `json:"note" example:"Approved after inspection"`
+}
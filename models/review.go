@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Review struct {
+	ID          uint          `gorm:"primaryKey"`
+	ProductID   uint          `gorm:"not null;uniqueIndex:idx_review_user_product"`
+	UserID      uint          `gorm:"not null;uniqueIndex:idx_review_user_product"`
+	OrderItemID uint          `gorm:"not null"` // Satın almayı doğrulayan sipariş kalemi
+	Rating      int           `gorm:"not null"` // 1-5
+	Title       string        `gorm:"not null"`
+	Body        string        `gorm:"type:text"`
+	Images      []ReviewImage `gorm:"foreignKey:ReviewID"`
+	SellerReply string        `gorm:"type:text"`
+	RepliedAt   *time.Time
+	Status      string `gorm:"not null;default:published;index"` // published, flagged, hidden
+	FlagReason  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+type ReviewImage struct {
+	ID       uint   `gorm:"primaryKey"`
+	ReviewID uint   `gorm:"not null;index"`
+	ImageUrl string `gorm:"type:text;not null"`
+}
+
+type CreateReviewRequest struct {
+	Rating int      `json:"rating" example:"5"`
+	Title  string   `json:"title" example:"Great product"`
+	Body   string   `json:"body" example:"Works as described."`
+	Images []string `json:"images"`
+}
+
+type ReviewReplyRequest struct {
+	Reply string `json:"reply" example:"Thank you for your feedback!"`
+}
+
+type ReviewModerationRequest struct {
+	Status string `json:"status" example:"hidden"`
+	Reason string `json:"reason" example:"Offensive language"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions. User.Role holds the role name.
+type Role struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null;uniqueIndex;size:50"`
+	Description string
+	Builtin     bool         `gorm:"not null;default:false"` // Varsayılan roller silinemez
+	SelfSignup  bool         `gorm:"not null;default:false"` // Kayıt olurken seçilebilir
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Permission is a resource:action[:scope] string such as orders:read:any.
+type Permission struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null;uniqueIndex;size:100"`
+	Description string
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name" example:"support"`
+	Description string   `json:"description" example:"Customer support agents"`
+	SelfSignup  *bool    `json:"self_signup" example:"false"` // Güncellemede gönderilmezse değişmez
+	Permissions []string `json:"permissions" example:"orders:read:any,users:read:any"`
+}
+
+type UserRoleRequest struct {
+	Role string `json:"role" example:"support"`
+}
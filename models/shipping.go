@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ShippingMethod struct {
+	ID        uint           `gorm:"primaryKey"`
+	ShopID    uint           `gorm:"not null;index"`
+	Name      string         `gorm:"not null"` // Standart, hızlı vb.
+	Carrier   string         `gorm:"not null"` // Kargo firması
+	Active    bool           `gorm:"not null;default:true"`
+	Rates     []ShippingRate `gorm:"foreignKey:ShippingMethodID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// ShippingMethodRequest is the body of a shipping method create or update. Active is a pointer so that an omitted
+// flag keeps the method active on create and unchanged on update; it takes the JSON key of the embedded method.
+type ShippingMethodRequest struct {
+	ShippingMethod
+	Active *bool
+}
+
+// ShippingRate is one row of a method's rate table. Zero maximums mean no upper limit.
+type ShippingRate struct {
+	ID               uint    `gorm:"primaryKey"`
+	ShippingMethodID uint    `gorm:"not null;index"`
+	MinWeight        float64 `gorm:"not null;default:0"` // kg
+	MaxWeight        float64 `gorm:"not null;default:0"` // kg
+	MinOrderAmount   float64 `gorm:"not null;default:0"`
+	MaxOrderAmount   float64 `gorm:"not null;default:0"`
+	Cost             float64 `gorm:"not null"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type Shipment struct {
+	ID           uint   `gorm:"primaryKey"`
+	OrderID      uint   `gorm:"not null;index"`
+	ShopID       uint   `gorm:"not null;index"`
+	Carrier      string `gorm:"not null"`
+	TrackingCode string `gorm:"not null"`
+	Status       string `gorm:"not null"` // label_created, in_transit, out_for_delivery, delivered, exception
+	ShippedAt    *time.Time
+	DeliveredAt  *time.Time
+	Events       []ShipmentEvent `gorm:"foreignKey:ShipmentID"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type ShipmentEvent struct {
+	ID          uint   `gorm:"primaryKey"`
+	ShipmentID  uint   `gorm:"not null;index"`
+	Status      string `gorm:"not null"`
+	Location    string
+	Description string    `gorm:"type:text"`
+	OccurredAt  time.Time `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+type ShippingQuote struct {
+	ShippingMethodID uint    `json:"shipping_method_id"`
+	Name             string  `json:"name"`
+	Carrier          string  `json:"carrier"`
+	Cost             float64 `json:"cost"`
+}
+
+type CreateShipmentRequest struct {
+	Carrier      string `json:"carrier" example:"UPS"`
+	TrackingCode string `json:"tracking_code" example:"1Z999AA10123456784"`
+}
+
+type ShipmentEventRequest struct {
+	Status      string    `json:"status" example:"in_transit"`
+	Location    string    `json:"location" example:"Istanbul"`
+	Description string    `json:"description" example:"Arrived at sorting center"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
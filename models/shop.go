@@ -7,10 +7,76 @@ import (
 )
 
 type Shop struct {
-	ID        uint           `gorm:"primaryKey"`
-	Name      string         `gorm:"not null"`
-	OwnerID   uint           `gorm:"not null"`
-	CreatedAt time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID             uint           `gorm:"primaryKey"`
+	Name           string         `gorm:"not null"`
+	OwnerID        uint           `gorm:"not null"`
+	Description    string         `gorm:"type:text"`
+	LogoUrl        string         `gorm:"type:text"`
+	BannerUrl      string         `gorm:"type:text"`
+	ReturnPolicy   string         `gorm:"type:text"`
+	ShippingPolicy string         `gorm:"type:text"`
+	RatingAvg      float64        `gorm:"not null;default:0"` // Mağaza puanlarının ortalaması
+	RatingCount    int            `gorm:"not null;default:0"`
+	Status         string         `gorm:"not null;default:active;size:20"` // active, suspended, closed
+	StatusReason   string         // Askıya alma veya kapatma nedeni
+	CreatedAt      time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+
+	// Satıcı başvurusu: onaylanmayan mağazalar ve ürünleri katalogda görünmez
+	Approval    string `gorm:"not null;default:approved;size:20"` // draft, submitted, approved, rejected
+	TaxID       string `gorm:"size:20"`
+	Address     string `gorm:"type:text"`
+	IBAN        string `gorm:"size:34"`
+	ReviewNote  string `gorm:"type:text"` // Reddetme nedeni
+	SubmittedAt *time.Time
+	ReviewedAt  *time.Time
+}
+
+// ShopRating is a customer's rating of a shop for a delivered shop order.
+type ShopRating struct {
+	ID        uint   `gorm:"primaryKey"`
+	ShopID    uint   `gorm:"not null;index"`
+	UserID    uint   `gorm:"not null;index"`
+	OrderID   uint   `gorm:"not null;uniqueIndex"`
+	Rating    int    `gorm:"not null"` // 1-5
+	Comment   string `gorm:"type:text"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ShopRatingRequest struct {
+	Rating  int    `json:"rating" example:"5"`
+	Comment string `json:"comment" example:"Fast shipping, well packed."`
+}
+
+type ShopReputation struct {
+	RatingAvg           float64  `json:"rating_avg"`
+	RatingCount         int      `json:"rating_count"`
+	CompletedOrders     int64    `json:"completed_orders"`
+	OnTimeShippingRate  *float64 `json:"on_time_shipping_rate"`   // 0-1, gönderilen sipariş yoksa boş
+	CancellationRate    *float64 `json:"cancellation_rate"`       // 0-1, sipariş yoksa boş
+	AvgResponseTimeHour *float64 `json:"avg_response_time_hours"` // Yorum yanıtları ve iade kararları
+}
+
+type ShopBusinessRequest struct {
+	TaxID   string `json:"tax_id" example:"1234567890"`
+	Address string `json:"address" example:"Atatürk Cad. No:1, Kadıköy, İstanbul"`
+	IBAN    string `json:"iban" example:"TR330006100519786457841326"`
+}
+
+type ShopReviewRequest struct {
+	Reason string `json:"reason" example:"Tax ID does not match the company name"`
+}
+
+// MyShop is a shop the user is a member of, with their shop role.
+type MyShop struct {
+	Shop
+	ShopRole string `json:"shop_role"`
+}
+
+// ShopProfile is the public view of a shop.
+type ShopProfile struct {
+	Shop
+	Reputation ShopReputation `json:"reputation"`
 }
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ShopMember gives a user a role in a shop: owner, manager, fulfillment or viewer.
+type ShopMember struct {
+	ID        uint   `gorm:"primaryKey"`
+	ShopID    uint   `gorm:"not null;uniqueIndex:idx_shop_member"`
+	UserID    uint   `gorm:"not null;uniqueIndex:idx_shop_member;index"`
+	Role      string `gorm:"not null;size:20"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ShopInvitation is an emailed invitation to join a shop. Only the hash of its token is stored.
+type ShopInvitation struct {
+	ID          uint   `gorm:"primaryKey"`
+	ShopID      uint   `gorm:"not null;index"`
+	Email       string `gorm:"not null;size:255"`
+	Role        string `gorm:"not null;size:20"`
+	TokenHash   string `gorm:"not null;uniqueIndex;size:64" json:"-"`
+	InvitedByID uint   `gorm:"not null"`
+	ExpiresAt   time.Time
+	AcceptedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+// ShopMemberView is a shop member with the user's contact details.
+type ShopMemberView struct {
+	UserID    uint      `json:"user_id"`
+	Name      string    `json:"name"`
+	Surname   string    `json:"surname"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ShopInvitationRequest struct {
+	Email string `json:"email" example:"employee@example.com"`
+	Role  string `json:"role" example:"fulfillment"`
+}
+
+type ShopMemberRoleRequest struct {
+	Role string `json:"role" example:"manager"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" example:"3f9c2b..."`
+}
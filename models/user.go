@@ -7,15 +7,31 @@ import (
 )
 
 type User struct {
-	ID        uint   `gorm:"primaryKey"`
-	Name      string `gorm:"not null"`
-	Surname   string `gorm:"not null"`
-	Email     string `gorm:"unique;not null"`
-	Password  string `gorm:"not null"`
-	Role      string `gorm:"not null"` //admin, seller, customer
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm"index"`
+	ID                 uint       `gorm:"primaryKey"`
+	Name               string     `gorm:"not null"`
+	Surname            string     `gorm:"not null"`
+	Email              string     `gorm:"unique;not null"`
+	Password           string     `gorm:"not null"`
+	Role               string     `gorm:"not null"`            //admin, seller, customer
+	Locale             string     `gorm:"not null;default:en"` // Bildirim dili: en, tr
+	EmailVerifiedAt    *time.Time // Boşsa e-posta doğrulanmamış
+	VerificationSentAt *time.Time // Son doğrulama e-postasının gönderildiği zaman
+	TokensRevokedAt    *time.Time // Bu zamandan önce verilen oturum tokenları geçersiz
+	TOTPSecret         string     `json:"-"` // İki adımlı doğrulama anahtarı
+	TOTPEnabled        bool       `gorm:"not null;default:false"`
+	TOTPLastStep       int64      `gorm:"not null;default:0" json:"-"` // Son kabul edilen kodun zaman adımı, tekrar kullanımı engeller
+	FailedLoginCount   int        `gorm:"not null;default:0" json:"-"` // Son başarılı girişten beri ardışık hatalı deneme sayısı
+	LockedUntil        *time.Time // Hesap bu zamana kadar kilitli
+	LastLoginAt        *time.Time
+	LastFailedLoginAt  *time.Time
+	SuspendedAt        *time.Time // Yönetici tarafından askıya alındıysa dolu
+	SuspensionReason   string
+	ErasedAt           *time.Time // Kişisel veriler silindiyse dolu, hesap anonimleştirilmiş
+	ClosedAt           *time.Time // Kullanıcı hesabını kapattıysa dolu, DeleteAfter'a kadar yeniden açılabilir
+	DeleteAfter        *time.Time // Kapatılan hesabın kalıcı olarak silineceği zaman
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
 type PasswordUpdateRequest struct {
@@ -23,7 +39,48 @@ type PasswordUpdateRequest struct {
 	NewPassword string `json:"new_password" example:"new_password123"`
 }
 
+// CloseAccountRequest confirms an account closure. Shops listed in TransferShops (shop ID to user ID) are handed
+// over to that shop member; the user's other shops are closed.
+type CloseAccountRequest struct {
+	Password      string        `json:"password" example:"password123"`
+	TransferShops map[uint]uint `json:"transfer_shops"`
+}
+
+// RegisterRequest is the body of a sign up. The account state is never taken from the client.
+type RegisterRequest struct {
+	Name     string `json:"name" example:"Ada"`
+	Surname  string `json:"surname" example:"Lovelace"`
+	Email    string `json:"email" example:"user@example.com"`
+	Password string `json:"password" example:"password123"`
+	Role     string `json:"role" example:"customer"`
+	Locale   string `json:"locale" example:"en"` // Boşsa veya desteklenmiyorsa en
+}
+
 type LoginRequest struct {
 	Email    string `json:"email" example:"user@example.com"`
 	Password string `json:"password" example:"password123"`
 }
+
+// RecoveryCode is a single-use code to sign in when the authenticator app is not available. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;size:64"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+type TOTPDisableRequest struct {
+	Password string `json:"password" example:"password123"`
+	Code     string `json:"code" example:"123456"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"a1b2-c3d4-e5f6"`
+}
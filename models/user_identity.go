@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an external identity provider.
+type UserIdentity struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	Provider  string `gorm:"not null;size:50;uniqueIndex:idx_provider_subject"` // google, github, ...
+	Subject   string `gorm:"not null;size:255;uniqueIndex:idx_provider_subject"`
+	Email     string
+	CreatedAt time.Time
+}
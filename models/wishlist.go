@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type WishlistItem struct {
+	ID        uint    `gorm:"primaryKey"`
+	UserID    uint    `gorm:"not null;uniqueIndex:idx_wishlist_user_product"`
+	ProductID uint    `gorm:"not null;uniqueIndex:idx_wishlist_user_product;index"`
+	Product   Product `gorm:"foreignKey:ProductID"`
+	CreatedAt time.Time
+}
+
+type WishlistRequest struct {
+	ProductID uint `json:"product_id" example:"1"`
+}
@@ -0,0 +1,190 @@
+package notification
+
+import (
+	"context"
+	"e_commerce/config"
+	"e_commerce/models"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// LogNotifier writes messages to the log instead of delivering them.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(msg Message) error {
+	log.Printf("Notification to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// MaxAttempts is how many times the worker tries to deliver a notification before giving up.
+const MaxAttempts = 5
+
+// claimTimeout is how long a notification may stay claimed before another worker takes it over, for workers
+// that stopped while sending.
+const claimTimeout = 10 * time.Minute
+
+// secretTemplates carry a single-use link, such as a password reset or an unlock link. The outbox only keeps
+// their body until the message is sent or given up, and the personal data export leaves it out, so the
+// stored rows never hold a usable link for longer than delivery takes.
+var secretTemplates = map[string]bool{
+	"password_reset":  true,
+	"verify_email":    true,
+	"account_locked":  true,
+	"shop_invitation": true,
+}
+
+// HasSecret reports whether messages of the template carry a single-use link.
+func HasSecret(template string) bool {
+	return secretTemplates[template]
+}
+
+// ScrubSecrets blanks the body of the delivered and failed messages that carry a single-use link, for
+// messages stored before bodies were blanked on delivery.
+func ScrubSecrets(db *gorm.DB) error {
+	templates := make([]string, 0, len(secretTemplates))
+	for template := range secretTemplates {
+		templates = append(templates, template)
+	}
+	return db.Model(&models.Notification{}).
+		Where("template IN ? AND status IN ? AND (text <> '' OR html <> '')", templates, []string{"sent", "failed"}).
+		Updates(map[string]interface{}{"text": "", "html": ""}).Error
+}
+
+// NewNotifier returns the SMTP notifier when an SMTP host is configured and the log notifier otherwise.
+func NewNotifier(cfg config.Mail) Notifier {
+	if cfg.SMTPHost == "" {
+		return LogNotifier{}
+	}
+
+	return &SMTPNotifier{
+		Addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		From:     cfg.From,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+	}
+}
+
+// Enqueue renders a template in the user's language and stores the message in the outbox.
+func Enqueue(db *gorm.DB, userID uint, template string, data map[string]interface{}) error {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["User"] = user
+
+	return enqueue(db, user.ID, user.Email, user.Locale, template, data)
+}
+
+// EnqueueEmail renders a template and stores the message for an address that may not belong to a user yet.
+func EnqueueEmail(db *gorm.DB, to, locale, template string, data map[string]interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return enqueue(db, 0, to, locale, template, data)
+}
+
+func enqueue(db *gorm.DB, userID uint, to, locale, template string, data map[string]interface{}) error {
+	msg, err := Render(locale, template, data)
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&models.Notification{
+		UserID:    userID,
+		To:        to,
+		Template:  template,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Status:    "pending",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}).Error
+}
+
+// Notify enqueues a notification and logs failures, for hooks that must not fail the request.
+func Notify(db *gorm.DB, userID uint, template string, data map[string]interface{}) {
+	if err := Enqueue(db, userID, template, data); err != nil {
+		log.Printf("Failed to enqueue %s notification for user %d: %v", template, userID, err)
+	}
+}
+
+// Deliver sends the pending notifications in the outbox. Each notification is claimed before it is sent,
+// so workers running at the same time never send the same message twice.
+func Deliver(db *gorm.DB, notifier Notifier, batchSize int) {
+	var pending []models.Notification
+	if err := db.Where("status = ? OR (status = ? AND updated_at < ?)", "pending", "sending", time.Now().Add(-claimTimeout)).
+		Order("id").Limit(batchSize).Find(&pending).Error; err != nil {
+		log.Println("Failed to load notifications:", err)
+		return
+	}
+
+	for _, n := range pending {
+		claim := db.Model(&models.Notification{}).Where("id = ? AND status = ? AND updated_at = ?", n.ID, n.Status, n.UpdatedAt).
+			Updates(map[string]interface{}{"status": "sending", "updated_at": time.Now()})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		err := notifier.Send(Message{To: n.To, Subject: n.Subject, Text: n.Text, HTML: n.HTML})
+
+		n.Attempts++
+		n.Status = "pending"
+		n.UpdatedAt = time.Now()
+		if err == nil {
+			n.Status = "sent"
+			n.SentAt = &n.UpdatedAt
+			n.LastError = ""
+		} else {
+			n.LastError = err.Error()
+			if n.Attempts >= MaxAttempts {
+				n.Status = "failed"
+			}
+			log.Printf("Notification %d to %s failed: %v", n.ID, n.To, err)
+		}
+		if n.Status != "pending" && HasSecret(n.Template) {
+			n.Text, n.HTML = "", ""
+		}
+		db.Save(&n)
+	}
+}
+
+// StartWorker delivers the outbox every interval until ctx is cancelled. wg is done once a delivery that
+// is running when ctx is cancelled has finished.
+func StartWorker(ctx context.Context, wg *sync.WaitGroup, db *gorm.DB, notifier Notifier, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				Deliver(db, notifier, 100)
+			}
+		}
+	}()
+}
@@ -0,0 +1,169 @@
+package notification
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP accepts one message on a local port and returns the envelope and the data the client sent.
+func fakeSMTP(t *testing.T) (addr string, received <-chan []string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	done := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var envelope []string
+		var data strings.Builder
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				envelope = append(envelope, cmd)
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				done <- append(envelope, data.String())
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), done
+}
+
+func TestSMTPNotifierSendsMultipartMessage(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	n := &SMTPNotifier{Addr: addr, From: "shop@example.com"}
+
+	err := n.Send(Message{
+		To:      "ayse@example.com",
+		Subject: "Siparişiniz kargoda",
+		Text:    "Order shipped",
+		HTML:    "<p>Order shipped</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-received
+	if len(got) != 3 {
+		t.Fatalf("got %d envelope lines and data, want MAIL, RCPT and data", len(got))
+	}
+	if !strings.HasPrefix(got[0], "MAIL FROM:<shop@example.com>") {
+		t.Errorf("MAIL = %q", got[0])
+	}
+	if got[1] != "RCPT TO:<ayse@example.com>" {
+		t.Errorf("RCPT = %q", got[1])
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(got[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from := msg.Header.Get("From"); from != "shop@example.com" {
+		t.Errorf("From = %q", from)
+	}
+	if to := msg.Header.Get("To"); to != "ayse@example.com" {
+		t.Errorf("To = %q", to)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Siparişiniz kargoda" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if v := msg.Header.Get("MIME-Version"); v != "1.0" {
+		t.Errorf("MIME-Version = %q", v)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+
+	want := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "Order shipped"},
+		{"text/html; charset=utf-8", "<p>Order shipped</p>"},
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, w := range want {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("part %s: %v", w.contentType, err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != w.contentType {
+			t.Errorf("part Content-Type = %q, want %q", ct, w.contentType)
+		}
+		body, _ := io.ReadAll(part)
+		if strings.TrimRight(string(body), "\r\n") != w.body {
+			t.Errorf("%s body = %q, want %q", w.contentType, body, w.body)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("expected two parts, next part error = %v", err)
+	}
+}
+
+func TestSMTPNotifierSendsPlainTextWithoutHTML(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	n := &SMTPNotifier{Addr: addr, From: "shop@example.com"}
+
+	if err := n.Send(Message{To: "ayse@example.com", Subject: "Hello", Text: "Plain body"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-received
+	msg, err := mail.ReadMessage(strings.NewReader(got[len(got)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if strings.TrimRight(string(body), "\r\n") != "Plain body" {
+		t.Errorf("body = %q", body)
+	}
+}
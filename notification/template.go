@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// DefaultLocale is used when a template is missing in the user's language.
+const DefaultLocale = "en"
+
+// Render builds a message from templates/<locale>/<name>.subject, .txt and the optional .html template.
+func Render(locale, name string, data interface{}) (Message, error) {
+	var msg Message
+	if _, err := templateFS.ReadFile(templatePath(locale, name, "subject")); err != nil {
+		locale = DefaultLocale
+	}
+
+	subject, err := renderText(templatePath(locale, name, "subject"), data)
+	if err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.TrimSpace(subject)
+
+	if msg.Text, err = renderText(templatePath(locale, name, "txt"), data); err != nil {
+		return msg, err
+	}
+
+	htmlPath := templatePath(locale, name, "html")
+	if _, err := templateFS.ReadFile(htmlPath); err == nil {
+		t, err := htmltemplate.ParseFS(templateFS, htmlPath)
+		if err != nil {
+			return msg, err
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return msg, err
+		}
+		msg.HTML = buf.String()
+	}
+
+	return msg, nil
+}
+
+func renderText(path string, data interface{}) (string, error) {
+	t, err := texttemplate.ParseFS(templateFS, path)
+	if err != nil {
+		return "", fmt.Errorf("notification template %s: %w", path, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func templatePath(locale, name, kind string) string {
+	return fmt.Sprintf("templates/%s/%s.%s", locale, name, kind)
+}
+
+// SupportedLocale reports whether templates exist for the locale.
+func SupportedLocale(locale string) bool {
+	if locale == "" || strings.ContainsAny(locale, "./") {
+		return false
+	}
+	_, err := templateFS.ReadDir("templates/" + locale)
+	return err == nil
+}
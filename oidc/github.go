@@ -0,0 +1,48 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// githubIdentity reads the user and their primary verified email from the GitHub API.
+func (p *Provider) githubIdentity(ctx context.Context, accessToken string) (Identity, error) {
+	if accessToken == "" {
+		return Identity{}, errors.New("token response has no access_token")
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, p.UserInfoURL, accessToken, &user); err != nil {
+		return Identity{}, err
+	}
+	if user.ID == 0 {
+		return Identity{}, errors.New("github user has no id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.UserInfoURL+"/emails", accessToken, &emails); err != nil {
+		return Identity{}, err
+	}
+
+	id := Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if id.Name == "" {
+		id.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			id.Email = e.Email
+			id.EmailVerified = e.Verified
+			break
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,227 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const mockKeyID = "mock"
+
+// MockIssuer is a minimal OpenID Connect issuer for local development and testing.
+// It signs every authorization request in without asking for credentials, as the user given in
+// the login_hint parameter or as Email when there is none.
+type MockIssuer struct {
+	Issuer   string
+	ClientID string
+	Email    string
+	Name     string
+
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]mockGrant
+	tokens map[string]jwt.MapClaims
+}
+
+type mockGrant struct {
+	claims      jwt.MapClaims
+	redirectURI string
+	challenge   string
+	expiresAt   time.Time
+}
+
+func NewMockIssuer(issuer, clientID string) (*MockIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MockIssuer{
+		Issuer:   strings.TrimSuffix(issuer, "/"),
+		ClientID: clientID,
+		Email:    "mock.user@example.com",
+		Name:     "Mock User",
+		key:      key,
+		codes:    map[string]mockGrant{},
+		tokens:   map[string]jwt.MapClaims{},
+	}, nil
+}
+
+func (m *MockIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := ""
+	if u, err := url.Parse(m.Issuer); err == nil {
+		base = strings.TrimSuffix(u.Path, "/")
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, base) {
+	case "/.well-known/openid-configuration":
+		m.discovery(w)
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	case "/userinfo":
+		m.userinfo(w, r)
+	case "/jwks":
+		m.jwks(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockIssuer) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                m.Issuer,
+		"authorization_endpoint":                m.Issuer + "/authorize",
+		"token_endpoint":                        m.Issuer + "/token",
+		"userinfo_endpoint":                     m.Issuer + "/userinfo",
+		"jwks_uri":                              m.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *MockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.ClientID || q.Get("redirect_uri") == "" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		email = m.Email
+	}
+	claims := jwt.MapClaims{
+		"iss":            m.Issuer,
+		"sub":            "mock|" + email,
+		"aud":            m.ClientID,
+		"email":          email,
+		"email_verified": true,
+		"name":           m.Name,
+	}
+	if parts := strings.SplitN(m.Name, " ", 2); len(parts) == 2 {
+		claims["given_name"] = parts[0]
+		claims["family_name"] = parts[1]
+	}
+	if nonce := q.Get("nonce"); nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	code := RandomString()
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		claims:      claims,
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *MockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	m.mu.Lock()
+	grant, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+
+	if !ok || time.Now().After(grant.expiresAt) ||
+		r.PostForm.Get("client_id") != m.ClientID ||
+		r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		Challenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{"iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mockKeyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken := RandomString()
+	m.mu.Lock()
+	m.tokens[accessToken] = claims
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (m *MockIssuer) userinfo(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	claims, ok := m.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	m.mu.Unlock()
+	if !ok {
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+
+	info := map[string]interface{}{}
+	for _, k := range []string{"sub", "email", "email_verified", "name", "given_name", "family_name"} {
+		if v, ok := claims[k]; ok {
+			info[k] = v
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (m *MockIssuer) jwks(w http.ResponseWriter) {
+	pub := m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mockKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
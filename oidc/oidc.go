@@ -0,0 +1,433 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"e_commerce/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	KindOIDC   = "oidc"
+	KindGitHub = "github"
+)
+
+// Client is the HTTP client used to talk to the providers.
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+// Provider is an identity provider users can sign in with.
+// The endpoints of an "oidc" provider are read from the discovery document of its issuer on first use;
+// a "github" provider uses the GitHub OAuth endpoints and REST API.
+type Provider struct {
+	Name         string
+	Kind         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	Scopes       []string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+// Identity is the user returned by a provider after a successful sign in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+}
+
+// VerifiedEmail returns the email address an existing account may be linked by. It is only given when
+// the provider has verified that the user owns the address.
+func (id Identity) VerifiedEmail() (string, bool) {
+	email := strings.TrimSpace(id.Email)
+	if email == "" || !id.EmailVerified {
+		return "", false
+	}
+	return email, true
+}
+
+// Providers holds the configured providers by name.
+type Providers map[string]*Provider
+
+func (providers Providers) Get(name string) (*Provider, bool) {
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Names returns the names of the configured providers in alphabetical order.
+func (providers Providers) Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProviders returns the configured providers (e.g. google, github, mock).
+// google and github need only the client credentials.
+func NewProviders(configured []config.OIDCProvider) Providers {
+	providers := Providers{}
+	for _, c := range configured {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		if name == "" {
+			continue
+		}
+
+		p := &Provider{
+			Name:         name,
+			Kind:         c.Kind,
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Issuer:       c.Issuer,
+		}
+
+		switch name {
+		case "google":
+			if p.Issuer == "" {
+				p.Issuer = "https://accounts.google.com"
+			}
+		case "github":
+			if p.Kind == "" {
+				p.Kind = KindGitHub
+			}
+		}
+		if p.Kind == "" {
+			p.Kind = KindOIDC
+		}
+
+		if p.ClientID == "" || (p.Kind == KindOIDC && p.Issuer == "") {
+			log.Printf("OIDC provider %s is not configured, skipped", name)
+			continue
+		}
+		providers[name] = p
+	}
+	return providers
+}
+
+// AuthCodeURL returns the URL of the provider's consent page for the authorization code flow with PKCE.
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(p.scopes(), " "))
+	q.Set("state", state)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	if p.Kind == KindOIDC {
+		q.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the signed in user.
+func (p *Provider) Exchange(ctx context.Context, code, redirectURI, verifier, nonce string) (Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return Identity{}, fmt.Errorf("token response: %w", err)
+	}
+	if token.Error != "" || resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("token request failed: %d %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+
+	if p.Kind == KindGitHub {
+		return p.githubIdentity(ctx, token.AccessToken)
+	}
+
+	id, err := p.verifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	// Some providers put the email only in the userinfo response.
+	if id.Email == "" && p.UserInfoURL != "" && token.AccessToken != "" {
+		var info map[string]interface{}
+		if err := getJSON(ctx, p.UserInfoURL, token.AccessToken, &info); err != nil {
+			return Identity{}, err
+		}
+		if sub, _ := info["sub"].(string); sub != id.Subject {
+			return Identity{}, errors.New("userinfo subject does not match the id token")
+		}
+		id.Email, _ = info["email"].(string)
+		id.EmailVerified = claimBool(info["email_verified"])
+	}
+	return id, nil
+}
+
+func (p *Provider) scopes() []string {
+	if len(p.Scopes) > 0 {
+		return p.Scopes
+	}
+	if p.Kind == KindGitHub {
+		return []string{"read:user", "user:email"}
+	}
+	return []string{"openid", "email", "profile"}
+}
+
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Kind == KindGitHub {
+		if p.AuthURL == "" {
+			p.AuthURL = "https://github.com/login/oauth/authorize"
+		}
+		if p.TokenURL == "" {
+			p.TokenURL = "https://github.com/login/oauth/access_token"
+		}
+		if p.UserInfoURL == "" {
+			p.UserInfoURL = "https://api.github.com/user"
+		}
+		return nil
+	}
+
+	if p.AuthURL != "" && p.TokenURL != "" && p.JWKSURL != "" {
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return fmt.Errorf("discovery for %s: %w", p.Name, err)
+	}
+	if doc.Issuer != p.Issuer {
+		return fmt.Errorf("discovery for %s: issuer %q does not match %q", p.Name, doc.Issuer, p.Issuer)
+	}
+
+	p.AuthURL = doc.AuthorizationEndpoint
+	p.TokenURL = doc.TokenEndpoint
+	p.UserInfoURL = doc.UserinfoEndpoint
+	p.JWKSURL = doc.JWKSURI
+	return nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (Identity, error) {
+	if raw == "" {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil || !token.Valid {
+		return Identity{}, fmt.Errorf("invalid id token: %v", err)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return Identity{}, fmt.Errorf("id token issuer %q does not match %q", iss, p.Issuer)
+	}
+	if !audienceContains(claims["aud"], p.ClientID) {
+		return Identity{}, errors.New("id token was not issued for this client")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return Identity{}, errors.New("id token has no expiry")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return Identity{}, errors.New("id token nonce does not match")
+	}
+
+	id := Identity{EmailVerified: claimBool(claims["email_verified"])}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	id.GivenName, _ = claims["given_name"].(string)
+	id.FamilyName, _ = claims["family_name"].(string)
+	if id.Subject == "" {
+		return Identity{}, errors.New("id token has no subject")
+	}
+	return id, nil
+}
+
+// key returns the signing key with the given id, refreshing the key set once when the id is unknown.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k := p.lookupKey(kid); k != nil {
+		return k, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.JWKSURL, "", &set); err != nil {
+		return nil, fmt.Errorf("jwks for %s: %w", p.Name, err)
+	}
+
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	if k := p.lookupKey(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) lookupKey(kid string) *rsa.PublicKey {
+	if k, ok := p.keys[kid]; ok {
+		return k
+	}
+	// A token without a key id can only be matched when the issuer publishes a single key.
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+	return nil
+}
+
+// RandomString returns a URL-safe random string for state, nonce and PKCE verifier values.
+func RandomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE challenge of a verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func getJSON(ctx context.Context, url, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, _ := a.(string); s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimBool reads a boolean claim; some providers send "true" as a string.
+func claimBool(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		ok, _ := strconv.ParseBool(b)
+		return ok
+	}
+	return false
+}
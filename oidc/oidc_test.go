@@ -0,0 +1,219 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testClientID = "shop-client"
+
+// newTestIssuer serves a mock issuer and returns it with a provider configured for it.
+func newTestIssuer(t *testing.T) (*MockIssuer, *Provider) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	issuer, err := NewMockIssuer(srv.URL+"/mock", testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle("/mock/", issuer)
+
+	return issuer, &Provider{Name: "mock", Kind: KindOIDC, ClientID: testClientID, ClientSecret: "secret", Issuer: issuer.Issuer}
+}
+
+// authorize runs the consent step of the mock issuer and returns the authorization code it redirects with.
+func authorize(t *testing.T, authURL, state string) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+
+	redirect, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := redirect.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	return redirect.Query().Get("code")
+}
+
+func TestDiscovery(t *testing.T) {
+	issuer, p := newTestIssuer(t)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "http://app/callback", "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AuthURL != issuer.Issuer+"/authorize" || p.TokenURL != issuer.Issuer+"/token" ||
+		p.UserInfoURL != issuer.Issuer+"/userinfo" || p.JWKSURL != issuer.Issuer+"/jwks" {
+		t.Errorf("endpoints not discovered: %+v", p)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != testClientID || q.Get("nonce") != "nonce" || q.Get("code_challenge") != Challenge("verifier") ||
+		q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+		t.Errorf("unexpected authorization parameters: %v", q)
+	}
+
+	other := &Provider{Name: "other", Kind: KindOIDC, ClientID: testClientID, Issuer: issuer.Issuer + "/elsewhere"}
+	if _, err := other.AuthCodeURL(context.Background(), "http://app/callback", "s", "n", "v"); err == nil {
+		t.Error("discovery of an unknown issuer succeeded")
+	}
+}
+
+func TestExchangeReturnsVerifiedIdentity(t *testing.T) {
+	_, p := newTestIssuer(t)
+	ctx := context.Background()
+	redirectURI := "http://app/auth/mock/callback"
+	verifier, nonce := RandomString(), RandomString()
+
+	authURL, err := p.AuthCodeURL(ctx, redirectURI, "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := authorize(t, authURL+"&login_hint="+url.QueryEscape("ayse@example.com"), "state-1")
+
+	id, err := p.Exchange(ctx, code, redirectURI, verifier, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "mock|ayse@example.com" || id.GivenName != "Mock" || id.FamilyName != "User" {
+		t.Errorf("identity = %+v", id)
+	}
+	if email, ok := id.VerifiedEmail(); !ok || email != "ayse@example.com" {
+		t.Errorf("VerifiedEmail = %q, %v", email, ok)
+	}
+
+	if _, err := p.Exchange(ctx, code, redirectURI, verifier, nonce); err == nil {
+		t.Error("an authorization code was redeemed twice")
+	}
+}
+
+func TestExchangeChecksPKCEAndNonce(t *testing.T) {
+	_, p := newTestIssuer(t)
+	ctx := context.Background()
+	redirectURI := "http://app/auth/mock/callback"
+
+	authURL, _ := p.AuthCodeURL(ctx, redirectURI, "s", "nonce-1", "verifier-1")
+	code := authorize(t, authURL, "s")
+	if _, err := p.Exchange(ctx, code, redirectURI, "another-verifier", "nonce-1"); err == nil {
+		t.Error("exchange with the wrong PKCE verifier succeeded")
+	}
+
+	authURL, _ = p.AuthCodeURL(ctx, redirectURI, "s", "nonce-2", "verifier-2")
+	code = authorize(t, authURL, "s")
+	if _, err := p.Exchange(ctx, code, redirectURI, "verifier-2", "another-nonce"); err == nil {
+		t.Error("exchange with the wrong nonce succeeded")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer, p := newTestIssuer(t)
+	ctx := context.Background()
+	if err := p.discover(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            issuer.Issuer,
+			"aud":            testClientID,
+			"sub":            "mock|user",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"nonce":          "nonce",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = mockKeyID
+		raw, err := token.SignedString(issuer.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	if id, err := p.verifyIDToken(ctx, sign(valid()), "nonce"); err != nil || id.Subject != "mock|user" {
+		t.Fatalf("valid token: %+v, %v", id, err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+	}{
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{"audience list without client", func(c jwt.MapClaims) { c["aud"] = []interface{}{"a", "b"} }},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "replayed" }},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		tt.modify(claims)
+		if _, err := p.verifyIDToken(ctx, sign(claims), "nonce"); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+
+	claims := valid()
+	claims["aud"] = []interface{}{"another-client", testClientID}
+	if _, err := p.verifyIDToken(ctx, sign(claims), "nonce"); err != nil {
+		t.Errorf("audience list with the client: %v", err)
+	}
+
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	raw, _ := hs.SignedString([]byte("secret"))
+	if _, err := p.verifyIDToken(ctx, raw, "nonce"); err == nil {
+		t.Error("HS256 token accepted")
+	}
+}
+
+func TestVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		id    Identity
+		email string
+		ok    bool
+	}{
+		{Identity{Email: "user@example.com", EmailVerified: true}, "user@example.com", true},
+		{Identity{Email: " user@example.com ", EmailVerified: true}, "user@example.com", true},
+		{Identity{Email: "user@example.com"}, "", false},
+		{Identity{EmailVerified: true}, "", false},
+	}
+	for _, tt := range tests {
+		email, ok := tt.id.VerifiedEmail()
+		if email != tt.email || ok != tt.ok {
+			t.Errorf("%+v: VerifiedEmail = %q, %v, want %q, %v", tt.id, email, ok, tt.email, tt.ok)
+		}
+	}
+
+	if !claimBool("true") || claimBool("false") || claimBool(nil) {
+		t.Error("claimBool does not read string booleans")
+	}
+}
@@ -0,0 +1,39 @@
+package payment
+
+import (
+	"fmt"
+	"log"
+)
+
+// Gateway is the payment provider used to move money back to customers and to shops. Both calls take an
+// idempotency key; a request repeated with the same key must not move the money again and returns the original reference.
+type Gateway interface {
+	Refund(key string, orderID uint, amount float64) (string, error)
+	Payout(key string, shopID uint, amount float64) (string, error)
+}
+
+// Default is the gateway used by the controllers.
+var Default Gateway = ManualGateway{}
+
+// ManualGateway records refunds and payouts for manual processing and returns a reference for them.
+type ManualGateway struct{}
+
+func (ManualGateway) Refund(key string, orderID uint, amount float64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("invalid refund amount: %.2f", amount)
+	}
+
+	ref := "manual-" + key
+	log.Printf("Refund %s queued for order %d: %.2f", ref, orderID, amount)
+	return ref, nil
+}
+
+func (ManualGateway) Payout(key string, shopID uint, amount float64) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("invalid payout amount: %.2f", amount)
+	}
+
+	ref := "manual-" + key
+	log.Printf("Payout %s queued for shop %d: %.2f", ref, shopID, amount)
+	return ref, nil
+}
@@ -0,0 +1,285 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"e_commerce/audit"
+	"e_commerce/models"
+	"e_commerce/notification"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOpenOrders = errors.New("privacy: user has orders or returns in progress")
+	ErrShopOwner  = errors.New("privacy: user owns a shop that is not closed")
+
+	openOrderStatuses  = []string{"pending", "confirmed", "processing", "shipped"}
+	openReturnStatuses = []string{"requested", "approved"}
+)
+
+// Export collects the personal data stored about a user.
+func Export(db *gorm.DB, userID uint) (models.PersonalDataExport, error) {
+	data := models.PersonalDataExport{ExportedAt: time.Now()}
+
+	if err := db.Model(&models.User{}).First(&data.Profile, userID).Error; err != nil {
+		return data, err
+	}
+
+	queries := []struct {
+		query *gorm.DB
+		dest  interface{}
+	}{
+		{db.Where("user_id = ?", userID), &data.Addresses},
+		{db.Preload("Items").Preload("Returns.Items").Preload("Shipments.Events").
+			Preload("SubOrders.Items").Preload("SubOrders.Returns.Items").Preload("SubOrders.Shipments.Events").
+			Where("user_id = ? AND parent_id IS NULL", userID).Order("created_at DESC"), &data.Orders},
+		{db.Preload("Images").Where("user_id = ?", userID), &data.Reviews},
+		{db.Where("user_id = ?", userID), &data.ShopRatings},
+		{db.Where("user_id = ?", userID), &data.Wishlist},
+		{db.Where("user_id = ?", userID), &data.Identities},
+		{db.Where("user_id = ?", userID), &data.ShopMembers},
+		{db.Where("user_id = ?", userID).Order("created_at DESC"), &data.LoginAttempts},
+		{db.Where("user_id = ?", userID).Order("created_at DESC"), &data.Notifications},
+	}
+	for _, q := range queries {
+		if err := q.query.Find(q.dest).Error; err != nil {
+			return data, err
+		}
+	}
+
+	// Messages that are still waiting to be sent may hold a usable reset or verification link.
+	for i, n := range data.Notifications {
+		if notification.HasSecret(n.Template) {
+			data.Notifications[i].Text, data.Notifications[i].HTML = "", ""
+		}
+	}
+	return data, nil
+}
+
+// WriteArchive writes the export as a ZIP archive with one JSON file per section.
+func WriteArchive(w io.Writer, data models.PersonalDataExport) error {
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"addresses.json", data.Addresses},
+		{"orders.json", data.Orders},
+		{"reviews.json", data.Reviews},
+		{"shop_ratings.json", data.ShopRatings},
+		{"wishlist.json", data.Wishlist},
+		{"identities.json", data.Identities},
+		{"shop_memberships.json", data.ShopMembers},
+		{"login_attempts.json", data.LoginAttempts},
+		{"notifications.json", data.Notifications},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// CheckOpenOrders returns ErrOpenOrders when the user has orders or returns in progress, as a customer
+// or at one of the given shops.
+func CheckOpenOrders(db *gorm.DB, userID uint, shopIDs []uint) error {
+	orders := db.Model(&models.Order{}).Where("status IN ?", openOrderStatuses)
+	returns := db.Model(&models.ReturnRequest{}).Where("status IN ?", openReturnStatuses)
+	if len(shopIDs) > 0 {
+		orders = orders.Where("user_id = ? OR shop_id IN ?", userID, shopIDs)
+		returns = returns.Where("user_id = ? OR shop_id IN ?", userID, shopIDs)
+	} else {
+		orders = orders.Where("user_id = ?", userID)
+		returns = returns.Where("user_id = ?", userID)
+	}
+
+	for _, query := range []*gorm.DB{orders, returns} {
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOpenOrders
+		}
+	}
+	return nil
+}
+
+// CheckErasable returns an error when the user's data cannot be erased yet: while orders or returns
+// are in progress the seller still needs the delivery details, and a shop needs an owner until it is closed.
+func CheckErasable(db *gorm.DB, userID uint) error {
+	if err := CheckOpenOrders(db, userID, nil); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(&models.Shop{}).Where("owner_id = ? AND status <> ?", userID, "closed").Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrShopOwner
+	}
+	return nil
+}
+
+// Erase anonymizes the personal data of a user. Orders, billing addresses and invoices are kept because
+// accounting has to retain them; everything else that identifies the user is deleted or blanked and the
+// account is closed. Audit log entries are append-only and are not touched; audit.Diff masks the personal
+// fields when they are recorded.
+func Erase(tx *gorm.DB, user *models.User, now time.Time) error {
+	email := user.Email
+
+	user.Name = "Deleted"
+	user.Surname = "User"
+	user.Email = fmt.Sprintf("erased-%d@invalid", user.ID)
+	user.Password = "" // Hiçbir bcrypt özetiyle eşleşmez, giriş yapılamaz
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.FailedLoginCount = 0
+	user.EmailVerifiedAt = nil
+	user.VerificationSentAt = nil
+	user.LockedUntil = nil
+	user.LastLoginAt = nil
+	user.LastFailedLoginAt = nil
+	user.SuspensionReason = ""
+	user.TokensRevokedAt = &now
+	user.ErasedAt = &now
+	user.UpdatedAt = now
+	if err := tx.Save(user).Error; err != nil {
+		return err
+	}
+
+	var reviewIDs []uint
+	if err := tx.Model(&models.Review{}).Unscoped().Where("user_id = ?", user.ID).Pluck("id", &reviewIDs).Error; err != nil {
+		return err
+	}
+	if len(reviewIDs) > 0 {
+		if err := tx.Where("review_id IN ?", reviewIDs).Delete(&models.ReviewImage{}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Puanlar ürün ve mağaza ortalamalarında kalır, yalnızca yazılan metinler silinir
+	updates := []struct {
+		model  interface{}
+		fields map[string]interface{}
+	}{
+		{&models.Review{}, map[string]interface{}{"title": "", "body": ""}},
+		{&models.ShopRating{}, map[string]interface{}{"comment": ""}},
+		{&models.ReturnRequest{}, map[string]interface{}{"reason": ""}},
+		{&models.Order{}, map[string]interface{}{
+			"shipping_full_name": "", "shipping_phone": "", "shipping_line1": "", "shipping_line2": "",
+			"shipping_city": "", "shipping_state": "", "shipping_postal_code": "",
+		}},
+	}
+	for _, u := range updates {
+		if err := tx.Model(u.model).Unscoped().Where("user_id = ?", user.ID).Updates(u.fields).Error; err != nil {
+			return err
+		}
+	}
+
+	deletes := []interface{}{
+		&models.Address{},
+		&models.WishlistItem{},
+		&models.UserIdentity{},
+		&models.ShopMember{},
+		&models.RecoveryCode{},
+		&models.PasswordResetToken{},
+		&models.RefreshToken{},
+	}
+	for _, model := range deletes {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("user_id = ? OR email = ?", user.ID, email).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ? OR `to` = ?", user.ID, email).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("email = ?", email).Delete(&models.ShopInvitation{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("email = ?", email).Delete(&models.PasswordResetRequest{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Delete(user).Error
+}
+
+// PurgeClosedAccounts erases and permanently deletes the closed accounts whose grace period has ended.
+// Accounts that cannot be erased yet are skipped and retried on the next run.
+func PurgeClosedAccounts(db *gorm.DB, now time.Time) (int, error) {
+	var users []models.User
+	if err := db.Where("closed_at IS NOT NULL AND delete_after <= ?", now).Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := CheckErasable(tx, user.ID); err != nil {
+				return err
+			}
+			if err := Erase(tx, &user, now); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&user).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, nil, audit.Entry{Action: "user.purge", EntityType: "user", EntityID: user.ID})
+		})
+		if err != nil {
+			log.Printf("Failed to delete closed account %d: %v", user.ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartPurgeScheduler runs PurgeClosedAccounts every interval until ctx is cancelled. wg is done once a
+// purge that is running when ctx is cancelled has finished.
+func StartPurgeScheduler(ctx context.Context, wg *sync.WaitGroup, db *gorm.DB, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			purged, err := PurgeClosedAccounts(db, time.Now())
+			if err != nil {
+				log.Println("Closed account purge failed:", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Deleted %d closed accounts", purged)
+			}
+		}
+	}()
+}
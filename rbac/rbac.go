@@ -0,0 +1,259 @@
+package rbac
+
+import (
+	"e_commerce/models"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Permissions are named resource:action:scope. The scope is own (the user's own data),
+// shop (the data of the user's shop) or any (everyone's data).
+const (
+	AccountCloseOwn    = "account:close:own"
+	OrdersCreate       = "orders:create"
+	OrdersReadShop     = "orders:read:shop"
+	OrdersUpdateShop   = "orders:update:shop"
+	OrdersReadAny      = "orders:read:any"
+	ReturnsCreateOwn   = "returns:create:own"
+	ReturnsReadShop    = "returns:read:shop"
+	ReturnsDecideShop  = "returns:decide:shop"
+	ReviewsCreate      = "reviews:create"
+	ReviewsReplyShop   = "reviews:reply:shop"
+	ReviewsModerateAny = "reviews:moderate:any"
+	RatingsCreate      = "ratings:create"
+	ShopsReadOwn       = "shops:read:own"
+	ShopsWriteOwn      = "shops:write:own"
+	ProductsReadOwn    = "products:read:own"
+	ProductsWriteOwn   = "products:write:own"
+	UsersReadAny       = "users:read:any"
+	UsersUnlockAny     = "users:unlock:any"
+	UsersDeleteAny     = "users:delete:any"
+	UsersRoleAny       = "users:role:any"
+	RolesManageAny     = "roles:manage:any"
+	CommissionAny      = "commission:manage:any"
+	PayoutsReadAny     = "payouts:read:any"
+	PayoutsRunAny      = "payouts:run:any"
+	UsersSuspendAny    = "users:suspend:any"
+	ShopsReadAny       = "shops:read:any"
+	ShopsModerateAny   = "shops:moderate:any"
+	ProductsReadAny    = "products:read:any"
+	ProductsUnpublish  = "products:unpublish:any"
+	OrdersUpdateAny    = "orders:update:any"
+	ShopsApproveAny    = "shops:approve:any"
+	AuditReadAny       = "audit:read:any"
+)
+
+// Catalog lists every permission with its description.
+var Catalog = []models.Permission{
+	{Name: AccountCloseOwn, Description: "Close own account"},
+	{Name: OrdersCreate, Description: "Place orders"},
+	{Name: OrdersReadShop, Description: "View the orders of own shop"},
+	{Name: OrdersUpdateShop, Description: "Update status and shipments of own shop's orders"},
+	{Name: OrdersReadAny, Description: "View any order"},
+	{Name: ReturnsCreateOwn, Description: "Request returns for own orders"},
+	{Name: ReturnsReadShop, Description: "View the return requests of own shop"},
+	{Name: ReturnsDecideShop, Description: "Approve or reject the return requests of own shop"},
+	{Name: ReviewsCreate, Description: "Review purchased products"},
+	{Name: ReviewsReplyShop, Description: "Reply to reviews of own shop's products"},
+	{Name: ReviewsModerateAny, Description: "Moderate any review"},
+	{Name: RatingsCreate, Description: "Rate shops of delivered orders"},
+	{Name: ShopsReadOwn, Description: "View own shop, balance, statement and payouts"},
+	{Name: ShopsWriteOwn, Description: "Create and update own shop and its shipping methods"},
+	{Name: ProductsReadOwn, Description: "List own shop's products"},
+	{Name: ProductsWriteOwn, Description: "Create and update own shop's products"},
+	{Name: UsersReadAny, Description: "View any user's login history"},
+	{Name: UsersUnlockAny, Description: "Unlock locked accounts"},
+	{Name: UsersDeleteAny, Description: "Delete any user"},
+	{Name: UsersRoleAny, Description: "Change the role of any user"},
+	{Name: RolesManageAny, Description: "Manage roles and their permissions"},
+	{Name: CommissionAny, Description: "Manage commission rules"},
+	{Name: PayoutsReadAny, Description: "View payout batches"},
+	{Name: PayoutsRunAny, Description: "Run payouts"},
+	{Name: UsersSuspendAny, Description: "Suspend and reactivate users"},
+	{Name: ShopsReadAny, Description: "List and search all shops"},
+	{Name: ShopsModerateAny, Description: "Suspend, close and reactivate shops"},
+	{Name: ProductsReadAny, Description: "List and search all products"},
+	{Name: ProductsUnpublish, Description: "Unpublish and republish products"},
+	{Name: OrdersUpdateAny, Description: "Override the status of any order"},
+	{Name: ShopsApproveAny, Description: "Approve or reject submitted shops"},
+	{Name: AuditReadAny, Description: "Search the audit log"},
+}
+
+// DefaultRole is the permission set a role is created with.
+type DefaultRole struct {
+	Name        string
+	Description string
+	SelfSignup  bool
+	Permissions []string
+}
+
+var Defaults = []DefaultRole{
+	{
+		Name:        "customer",
+		Description: "Buys products",
+		SelfSignup:  true,
+		Permissions: []string{AccountCloseOwn, OrdersCreate, ReturnsCreateOwn, ReviewsCreate, RatingsCreate},
+	},
+	{
+		Name:        "seller",
+		Description: "Runs a shop",
+		SelfSignup:  true,
+		Permissions: []string{
+			AccountCloseOwn, ShopsReadOwn, ShopsWriteOwn, ProductsReadOwn, ProductsWriteOwn,
+			OrdersReadShop, OrdersUpdateShop, ReturnsReadShop, ReturnsDecideShop, ReviewsReplyShop,
+		},
+	},
+	{
+		Name:        "support",
+		Description: "Customer support, read-only access to orders and users",
+		Permissions: []string{AccountCloseOwn, OrdersReadAny, UsersReadAny, ShopsReadAny, ProductsReadAny},
+	},
+	{
+		Name:        "admin",
+		Description: "Platform administrator",
+		Permissions: []string{
+			AccountCloseOwn, OrdersReadAny, ReviewsModerateAny, UsersReadAny, UsersUnlockAny, UsersDeleteAny, UsersRoleAny,
+			RolesManageAny, CommissionAny, PayoutsReadAny, PayoutsRunAny,
+			UsersSuspendAny, ShopsReadAny, ShopsModerateAny, ProductsReadAny, ProductsUnpublish, OrdersUpdateAny,
+			ShopsApproveAny, AuditReadAny,
+		},
+	},
+}
+
+// Seed creates the permissions and default roles that do not exist yet. A permission that is new to
+// the database is also granted to the existing default roles that include it.
+func Seed(db *gorm.DB) error {
+	for _, def := range Defaults {
+		var role models.Role
+		err := db.Where(models.Role{Name: def.Name}).
+			Attrs(models.Role{Description: def.Description, Builtin: true, SelfSignup: def.SelfSignup}).
+			FirstOrCreate(&role).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range Catalog {
+		perm := p
+		result := db.Where(models.Permission{Name: perm.Name}).Attrs(models.Permission{Description: perm.Description}).FirstOrCreate(&perm)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		for _, def := range Defaults {
+			if !contains(def.Permissions, perm.Name) {
+				continue
+			}
+			var role models.Role
+			if err := db.Where("name = ?", def.Name).First(&role).Error; err != nil {
+				return err
+			}
+			if err := db.Model(&role).Association("Permissions").Append(&perm); err != nil {
+				return err
+			}
+		}
+	}
+
+	Invalidate()
+	return nil
+}
+
+// GrantAccountClose grants the account closing permission to the default roles that lack it. Seed only
+// grants permissions that are new to the database, and every user must be able to close their account, so
+// this runs on each start.
+func GrantAccountClose(db *gorm.DB) error {
+	var perm models.Permission
+	if err := db.Where("name = ?", AccountCloseOwn).First(&perm).Error; err != nil {
+		return err
+	}
+
+	for _, def := range Defaults {
+		if !contains(def.Permissions, AccountCloseOwn) {
+			continue
+		}
+		var role models.Role
+		if err := db.Preload("Permissions").Where("name = ?", def.Name).First(&role).Error; err != nil {
+			return err
+		}
+		granted := false
+		for _, p := range role.Permissions {
+			granted = granted || p.Name == AccountCloseOwn
+		}
+		if granted {
+			continue
+		}
+		if err := db.Model(&role).Association("Permissions").Append(&perm); err != nil {
+			return err
+		}
+	}
+
+	Invalidate()
+	return nil
+}
+
+// cacheTTL bounds how long a permission change made by another instance can go unnoticed.
+const cacheTTL = 30 * time.Second
+
+var cache struct {
+	sync.Mutex
+	roles    map[string]map[string]bool
+	loadedAt time.Time
+}
+
+// Has reports whether the role has the permission.
+func Has(db *gorm.DB, role, permission string) bool {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.roles == nil || time.Since(cache.loadedAt) > cacheTTL {
+		roles, err := load(db)
+		if err != nil {
+			log.Printf("Failed to load role permissions: %v", err)
+			if cache.roles == nil {
+				return false
+			}
+		} else {
+			cache.roles = roles
+			cache.loadedAt = time.Now()
+		}
+	}
+	return cache.roles[role][permission]
+}
+
+// Invalidate drops the cached role permissions after they were changed.
+func Invalidate() {
+	cache.Lock()
+	cache.roles = nil
+	cache.Unlock()
+}
+
+func load(db *gorm.DB) (map[string]map[string]bool, error) {
+	var roles []models.Role
+	if err := db.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]map[string]bool, len(roles))
+	for _, role := range roles {
+		m[role.Name] = make(map[string]bool, len(role.Permissions))
+		for _, p := range role.Permissions {
+			m[role.Name][p.Name] = true
+		}
+	}
+	return m, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package rbac
+
+import (
+	"e_commerce/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Shop roles of a ShopMember.
+const (
+	ShopOwner       = "owner"
+	ShopManager     = "manager"
+	ShopFulfillment = "fulfillment"
+	ShopViewer      = "viewer"
+)
+
+// Actions a shop member can take in the shop.
+const (
+	ShopView     = "view"     // Mağaza, sipariş, iade ve ürünleri görüntüleme
+	ShopManage   = "manage"   // Mağaza profili, kargo yöntemleri, yorum yanıtları
+	ShopProducts = "products" // Ürün ekleme ve güncelleme
+	ShopFulfil   = "fulfil"   // Sipariş durumu, kargo ve iade kararları
+	ShopFinance  = "finance"  // Bakiye, hesap dökümü ve ödemeler
+	ShopMembers  = "members"  // Çalışan davetleri ve rolleri
+)
+
+var shopRoleActions = map[string][]string{
+	ShopOwner:       {ShopView, ShopManage, ShopProducts, ShopFulfil, ShopFinance, ShopMembers},
+	ShopManager:     {ShopView, ShopManage, ShopProducts, ShopFulfil, ShopFinance},
+	ShopFulfillment: {ShopView, ShopFulfil},
+	ShopViewer:      {ShopView},
+}
+
+// ValidShopRole reports whether role is a shop role.
+func ValidShopRole(role string) bool {
+	_, ok := shopRoleActions[role]
+	return ok
+}
+
+// ShopCan reports whether a member with the shop role may take the action.
+func ShopCan(role, action string) bool {
+	return contains(shopRoleActions[role], action)
+}
+
+// BackfillShopOwners adds the owner membership of shops created before shop members existed.
+func BackfillShopOwners(db *gorm.DB) error {
+	var shops []models.Shop
+	if err := db.Where("id NOT IN (?)", db.Model(&models.ShopMember{}).Select("shop_id").Where("role = ?", ShopOwner)).
+		Find(&shops).Error; err != nil {
+		return err
+	}
+
+	for _, shop := range shops {
+		if err := db.Create(&models.ShopMember{
+			ShopID:    shop.ID,
+			UserID:    shop.OwnerID,
+			Role:      ShopOwner,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
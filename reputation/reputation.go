@@ -0,0 +1,109 @@
+package reputation
+
+import (
+	"database/sql"
+	"e_commerce/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Compute calculates the reputation metrics of a shop from its orders, shipments, review replies and return decisions.
+// The shipping SLA is how long the shop may take to ship an order and still count as on time.
+func Compute(db *gorm.DB, shop models.Shop, shippingSLA time.Duration) (models.ShopReputation, error) {
+	rep := models.ShopReputation{
+		RatingAvg:   shop.RatingAvg,
+		RatingCount: shop.RatingCount,
+	}
+
+	var total, cancelled int64
+	counts := []struct {
+		status string
+		dest   *int64
+	}{
+		{"", &total},
+		{"cancelled", &cancelled},
+		{"delivered", &rep.CompletedOrders},
+	}
+	for _, c := range counts {
+		query := db.Model(&models.Order{}).Where("shop_id = ?", shop.ID)
+		if c.status != "" {
+			query = query.Where("status = ?", c.status)
+		}
+		if err := query.Count(c.dest).Error; err != nil {
+			return rep, err
+		}
+	}
+	if total > 0 {
+		rep.CancellationRate = ratio(float64(cancelled), float64(total))
+	}
+
+	var shipped []struct {
+		OrderedAt sql.NullTime
+		ShippedAt sql.NullTime
+	}
+	if err := db.Table("shipments").Select("MIN(orders.created_at) AS ordered_at, MIN(shipments.shipped_at) AS shipped_at").
+		Joins("JOIN orders ON orders.id = shipments.order_id").
+		Where("shipments.shop_id = ? AND shipments.shipped_at IS NOT NULL", shop.ID).
+		Group("shipments.order_id").Scan(&shipped).Error; err != nil {
+		return rep, err
+	}
+	if len(shipped) > 0 {
+		onTime := 0
+		for _, s := range shipped {
+			if s.OrderedAt.Valid && s.ShippedAt.Valid && s.ShippedAt.Time.Sub(s.OrderedAt.Time) <= shippingSLA {
+				onTime++
+			}
+		}
+		rep.OnTimeShippingRate = ratio(float64(onTime), float64(len(shipped)))
+	}
+
+	var responses []time.Duration
+	var replies []models.Review
+	if err := db.Joins("JOIN products ON products.id = reviews.product_id").
+		Where("products.shop_id = ? AND reviews.replied_at IS NOT NULL", shop.ID).Find(&replies).Error; err != nil {
+		return rep, err
+	}
+	for _, review := range replies {
+		responses = append(responses, review.RepliedAt.Sub(review.CreatedAt))
+	}
+
+	var decided []models.ReturnRequest
+	if err := db.Where("shop_id = ? AND status <> ?", shop.ID, "requested").Find(&decided).Error; err != nil {
+		return rep, err
+	}
+	for _, ret := range decided {
+		responses = append(responses, ret.UpdatedAt.Sub(ret.CreatedAt))
+	}
+
+	if len(responses) > 0 {
+		var sum time.Duration
+		for _, d := range responses {
+			sum += d
+		}
+		hours := (sum / time.Duration(len(responses))).Hours()
+		rep.AvgResponseTimeHour = &hours
+	}
+
+	return rep, nil
+}
+
+// UpdateRating recalculates the rating of a shop from its ratings.
+func UpdateRating(tx *gorm.DB, shopID uint) error {
+	var stats struct {
+		Avg   float64
+		Count int
+	}
+	if err := tx.Model(&models.ShopRating{}).Select("COALESCE(AVG(rating), 0) AS avg, COUNT(*) AS count").
+		Where("shop_id = ?", shopID).Scan(&stats).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Shop{}).Where("id = ?", shopID).
+		Updates(map[string]interface{}{"rating_avg": stats.Avg, "rating_count": stats.Count}).Error
+}
+
+func ratio(part, total float64) *float64 {
+	r := part / total
+	return &r
+}
@@ -3,34 +3,149 @@ package routes
 import (
 	"e_commerce/controller"
 	"e_commerce/middleware"
+	"e_commerce/rbac"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func InitRoutes() *mux.Router {
+func InitRoutes(h *controller.Handler, m *middleware.Middleware) *mux.Router {
 	r := mux.NewRouter()
+	r.Use(middleware.RequestID)
 
-	r.HandleFunc("/users/register", controller.RegisterHandler)                                                                                         //++
-	r.HandleFunc("/users/login", controller.LoginHandler)                                                                                               //++
-	r.Handle("/users/profile", middleware.JWTAuth(http.HandlerFunc(controller.GetProfile))).Methods("GET")                                              //++
-	r.Handle("/users/profile", middleware.JWTAuth(http.HandlerFunc(controller.UpdateProfile))).Methods("PUT")                                           //++
-	r.Handle("/users/profile/password", middleware.JWTAuth(http.HandlerFunc(controller.UpdatePassword))).Methods("PUT")                                 //++
-	r.Handle("/users/{user_id}/delete", middleware.JWTAuth(middleware.Authorize("admin")(http.HandlerFunc(controller.DeleteUser)))).Methods("DELETE")   //++
-	r.Handle("/users/close-account", middleware.JWTAuth(middleware.Authorize("customer")(http.HandlerFunc(controller.CloseAccount)))).Methods("DELETE") //++
-
-	r.Handle("/shop", middleware.JWTAuth(middleware.Authorize("seller")(http.HandlerFunc(controller.CreateShop)))).Methods("POST")  //++
-	r.Handle("/shop", middleware.JWTAuth(middleware.Authorize("seller")(http.HandlerFunc(controller.UpdateShop)))).Methods("PUT")   //++
-	r.Handle("/shop/my", middleware.JWTAuth(middleware.Authorize("seller")(http.HandlerFunc(controller.GetMyShop)))).Methods("GET") //--
-	r.Handle("/shop/{shop_id}", middleware.JWTAuth(http.HandlerFunc(controller.GetShop))).Methods("GET")                            //++
-
-	r.Handle("/product", middleware.JWTAuth(middleware.Authorize("seller")(http.HandlerFunc(controller.AddProduct)))).Methods("POST")                     //++
-	r.Handle("/product/{product_id}", middleware.JWTAuth(middleware.Authorize("seller")(http.HandlerFunc(controller.UpdateProduct)))).Methods("PUT")      //++
-	r.Handle("/product/my-products", middleware.JWTAuth(middleware.Authorize("seller")(http.HandlerFunc(controller.GetProductsByMyShop)))).Methods("GET") //++
-	r.Handle("/product/{product_id}", http.HandlerFunc(controller.GetProduct)).Methods("GET")                                                             //++
-	r.Handle("/product", http.HandlerFunc(controller.GetProducts)).Methods("GET")                                                                         //++
-	r.Handle("/product/{shop_id}/products", http.HandlerFunc(controller.GetProductsByShop)).Methods("GET")                                                //++
+	r.HandleFunc("/users/register", h.RegisterHandler) //++
+	r.HandleFunc("/users/login", h.LoginHandler)       //++
+	r.HandleFunc("/users/login/mfa", h.LoginMFA).Methods("POST")
+	r.HandleFunc("/users/verify", h.VerifyEmail).Methods("GET")
+	r.HandleFunc("/users/unlock", h.UnlockAccount).Methods("GET")
+	r.HandleFunc("/users/reactivate", h.ReactivateAccount).Methods("POST")
+	r.HandleFunc("/auth/providers", h.GetOIDCProviders).Methods("GET")
+	r.HandleFunc("/auth/{provider}/login", h.OIDCLogin).Methods("GET")
+	r.HandleFunc("/auth/{provider}/callback", h.OIDCCallback).Methods("GET")
+	r.HandleFunc("/users/password/forgot", h.ForgotPassword).Methods("POST")
+	r.HandleFunc("/users/password/reset", h.ResetPassword).Methods("POST")
+	r.Handle("/users/verify/resend", m.JWTAuth(http.HandlerFunc(h.ResendVerification))).Methods("POST")
+	r.Handle("/users/profile", m.JWTAuth(http.HandlerFunc(h.GetProfile))).Methods("GET")                                                                //++
+	r.Handle("/users/profile", m.JWTAuth(http.HandlerFunc(h.UpdateProfile))).Methods("PUT")                                                             //++
+	r.Handle("/users/profile/password", m.JWTAuth(http.HandlerFunc(h.UpdatePassword))).Methods("PUT")                                                   //++
+	r.Handle("/users/{user_id}/delete", m.JWTAuth(middleware.RequirePermission(rbac.UsersDeleteAny)(http.HandlerFunc(h.DeleteUser)))).Methods("DELETE") //++
+	r.Handle("/users/close-account", m.JWTAuth(middleware.RequirePermission(rbac.AccountCloseOwn)(http.HandlerFunc(h.CloseAccount)))).Methods("DELETE") //++
+
+	r.Handle("/users/2fa/setup", m.JWTAuth(http.HandlerFunc(h.SetupTOTP))).Methods("POST")
+	r.Handle("/users/2fa/enable", m.JWTAuth(http.HandlerFunc(h.EnableTOTP))).Methods("POST")
+	r.Handle("/users/2fa/disable", m.JWTAuth(http.HandlerFunc(h.DisableTOTP))).Methods("POST")
+	r.Handle("/users/2fa/recovery-codes", m.JWTAuth(http.HandlerFunc(h.RegenerateRecoveryCodes))).Methods("POST")
+
+	r.Handle("/users/profile/identities", m.JWTAuth(http.HandlerFunc(h.GetIdentities))).Methods("GET")
+	r.Handle("/users/profile/identities/{provider}", m.JWTAuth(http.HandlerFunc(h.UnlinkIdentity))).Methods("DELETE")
+
+	r.Handle("/users/profile/export", m.JWTAuth(http.HandlerFunc(h.ExportPersonalData))).Methods("GET")
+	r.Handle("/users/profile/erase", m.JWTAuth(http.HandlerFunc(h.ErasePersonalData))).Methods("POST")
+	r.Handle("/admin/users/{user_id}/erase", m.JWTAuth(middleware.RequirePermission(rbac.UsersDeleteAny)(http.HandlerFunc(h.AdminEraseUser)))).Methods("POST")
+
+	r.Handle("/users/profile/login-attempts", m.JWTAuth(http.HandlerFunc(h.GetLoginAttempts))).Methods("GET")
+	r.Handle("/admin/users/{user_id}/login-attempts", m.JWTAuth(middleware.RequirePermission(rbac.UsersReadAny)(http.HandlerFunc(h.GetUserLoginAttempts)))).Methods("GET")
+	r.Handle("/admin/users/{user_id}/unlock", m.JWTAuth(middleware.RequirePermission(rbac.UsersUnlockAny)(http.HandlerFunc(h.AdminUnlockUser)))).Methods("POST")
+
+	r.Handle("/users/profile/addresses", m.JWTAuth(http.HandlerFunc(h.GetAddresses))).Methods("GET")
+	r.Handle("/users/profile/addresses", m.JWTAuth(http.HandlerFunc(h.CreateAddress))).Methods("POST")
+	r.Handle("/users/profile/addresses/{address_id}", m.JWTAuth(http.HandlerFunc(h.GetAddress))).Methods("GET")
+	r.Handle("/users/profile/addresses/{address_id}", m.JWTAuth(http.HandlerFunc(h.UpdateAddress))).Methods("PUT")
+	r.Handle("/users/profile/addresses/{address_id}", m.JWTAuth(http.HandlerFunc(h.DeleteAddress))).Methods("DELETE")
+
+	r.Handle("/users/profile/wishlist", m.JWTAuth(http.HandlerFunc(h.GetWishlist))).Methods("GET")
+	r.Handle("/users/profile/wishlist", m.JWTAuth(http.HandlerFunc(h.AddToWishlist))).Methods("POST")
+	r.Handle("/users/profile/wishlist/{product_id}", m.JWTAuth(http.HandlerFunc(h.RemoveFromWishlist))).Methods("DELETE")
+
+	r.Handle("/shop", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(m.RequireVerifiedEmail(http.HandlerFunc(h.CreateShop))))).Methods("POST") //++
+	r.Handle("/shop", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.UpdateShop)))).Methods("PUT")                          //++
+	r.Handle("/shop/my", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadOwn)(http.HandlerFunc(h.GetMyShop)))).Methods("GET")                         //--
+	r.Handle("/shop/shipping-methods", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadOwn)(http.HandlerFunc(h.GetMyShippingMethods)))).Methods("GET")
+	r.Handle("/shop/shipping-methods", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.CreateShippingMethod)))).Methods("POST")
+	r.Handle("/shop/shipping-methods/{method_id}", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.UpdateShippingMethod)))).Methods("PUT")
+	r.Handle("/shop/shipping-methods/{method_id}", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.DeleteShippingMethod)))).Methods("DELETE")
+	r.Handle("/shop/orders", m.JWTAuth(middleware.RequirePermission(rbac.OrdersReadShop)(http.HandlerFunc(h.GetShopOrders)))).Methods("GET")
+	r.Handle("/shop/orders/{order_id}", m.JWTAuth(middleware.RequirePermission(rbac.OrdersReadShop)(http.HandlerFunc(h.GetShopOrder)))).Methods("GET")
+	r.Handle("/shop/balance", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadOwn)(http.HandlerFunc(h.GetShopBalance)))).Methods("GET")
+	r.Handle("/shop/statement", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadOwn)(http.HandlerFunc(h.GetShopStatement)))).Methods("GET")
+	r.Handle("/shop/payouts", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadOwn)(http.HandlerFunc(h.GetShopPayouts)))).Methods("GET")
+	r.Handle("/shop/returns", m.JWTAuth(middleware.RequirePermission(rbac.ReturnsReadShop)(http.HandlerFunc(h.GetShopReturns)))).Methods("GET")
+	r.Handle("/shop/business", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.UpdateShopBusiness)))).Methods("PUT")
+	r.Handle("/shop/submit", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.SubmitShop)))).Methods("POST")
+	r.Handle("/shop/members", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadOwn)(http.HandlerFunc(h.GetShopMembers)))).Methods("GET")
+	r.Handle("/shop/members/{user_id}", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.UpdateShopMember)))).Methods("PUT")
+	r.Handle("/shop/members/{user_id}", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.RemoveShopMember)))).Methods("DELETE")
+	r.Handle("/shop/invitations", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.GetShopInvitations)))).Methods("GET")
+	r.Handle("/shop/invitations", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.InviteShopMember)))).Methods("POST")
+	r.Handle("/shop/invitations/accept", m.JWTAuth(http.HandlerFunc(h.AcceptShopInvitation))).Methods("POST")
+	r.Handle("/shop/invitations/{invitation_id}", m.JWTAuth(middleware.RequirePermission(rbac.ShopsWriteOwn)(http.HandlerFunc(h.RevokeShopInvitation)))).Methods("DELETE")
+	r.Handle("/shop/{shop_id}", http.HandlerFunc(h.GetShop)).Methods("GET") //++
+	r.Handle("/shop/{shop_id}/ratings", http.HandlerFunc(h.GetShopRatings)).Methods("GET")
+	r.Handle("/shop/{shop_id}/shipping-methods", http.HandlerFunc(h.GetShopShippingMethods)).Methods("GET")
+
+	r.Handle("/product", m.JWTAuth(middleware.RequirePermission(rbac.ProductsWriteOwn)(http.HandlerFunc(h.AddProduct)))).Methods("POST")                    //++
+	r.Handle("/product/{product_id}", m.JWTAuth(middleware.RequirePermission(rbac.ProductsWriteOwn)(http.HandlerFunc(h.UpdateProduct)))).Methods("PUT")     //++
+	r.Handle("/product/my-products", m.JWTAuth(middleware.RequirePermission(rbac.ProductsReadOwn)(http.HandlerFunc(h.GetProductsByMyShop)))).Methods("GET") //++
+	r.Handle("/product/{product_id}", http.HandlerFunc(h.GetProduct)).Methods("GET")                                                                        //++
+	r.Handle("/product", http.HandlerFunc(h.GetProducts)).Methods("GET")                                                                                    //++
+	r.Handle("/product/{shop_id}/products", http.HandlerFunc(h.GetProductsByShop)).Methods("GET")                                                           //++
+	r.Handle("/product/{product_id}/reviews", m.JWTAuth(middleware.RequirePermission(rbac.ReviewsCreate)(http.HandlerFunc(h.CreateReview)))).Methods("POST")
+	r.Handle("/product/{product_id}/reviews", http.HandlerFunc(h.GetProductReviews)).Methods("GET")
+	r.Handle("/reviews/{review_id}/reply", m.JWTAuth(middleware.RequirePermission(rbac.ReviewsReplyShop)(http.HandlerFunc(h.ReplyToReview)))).Methods("PUT")
+	r.Handle("/reviews/{review_id}/flag", m.JWTAuth(http.HandlerFunc(h.FlagReview))).Methods("POST")
+	r.Handle("/product/{product_id}/shipping-quotes", http.HandlerFunc(h.GetShippingQuotes)).Methods("GET")
+
+	r.Handle("/orders", m.JWTAuth(http.HandlerFunc(h.GetMyOrders))).Methods("GET")
+	r.Handle("/orders/checkout", m.JWTAuth(middleware.RequirePermission(rbac.OrdersCreate)(m.RequireVerifiedEmail(http.HandlerFunc(h.Checkout))))).Methods("POST")
+	r.Handle("/orders/{product_id}", m.JWTAuth(middleware.RequirePermission(rbac.OrdersCreate)(m.RequireVerifiedEmail(http.HandlerFunc(h.CreateOrder))))).Methods("POST")
+	r.Handle("/orders/{order_id}", m.JWTAuth(http.HandlerFunc(h.GetOrder))).Methods("GET")
+	r.Handle("/orders/{order_id}/invoice.pdf", m.JWTAuth(http.HandlerFunc(h.GetOrderInvoice))).Methods("GET")
+	r.Handle("/orders/{order_id}/shop-rating", m.JWTAuth(middleware.RequirePermission(rbac.RatingsCreate)(http.HandlerFunc(h.RateShop)))).Methods("POST")
+	r.Handle("/orders/{order_id}/status", m.JWTAuth(middleware.RequirePermission(rbac.OrdersUpdateShop)(http.HandlerFunc(h.UpdateOrderStatus)))).Methods("PUT")
+
+	r.Handle("/orders/{order_id}/shipments", m.JWTAuth(middleware.RequirePermission(rbac.OrdersUpdateShop)(http.HandlerFunc(h.CreateShipment)))).Methods("POST")
+	r.Handle("/orders/{order_id}/shipments", m.JWTAuth(http.HandlerFunc(h.GetOrderShipments))).Methods("GET")
+	r.Handle("/orders/{order_id}/shipments/{shipment_id}/events", m.JWTAuth(middleware.RequirePermission(rbac.OrdersUpdateShop)(http.HandlerFunc(h.AddShipmentEvent)))).Methods("POST")
+
+	r.Handle("/orders/{order_id}/returns", m.JWTAuth(middleware.RequirePermission(rbac.ReturnsCreateOwn)(http.HandlerFunc(h.CreateReturn)))).Methods("POST")
+	r.Handle("/orders/{order_id}/returns", m.JWTAuth(http.HandlerFunc(h.GetOrderReturns))).Methods("GET")
+	r.Handle("/returns/{return_id}/approve", m.JWTAuth(middleware.RequirePermission(rbac.ReturnsDecideShop)(http.HandlerFunc(h.ApproveReturn)))).Methods("PUT")
+	r.Handle("/returns/{return_id}/reject", m.JWTAuth(middleware.RequirePermission(rbac.ReturnsDecideShop)(http.HandlerFunc(h.RejectReturn)))).Methods("PUT")
+
+	r.Handle("/admin/permissions", m.JWTAuth(middleware.RequirePermission(rbac.RolesManageAny)(http.HandlerFunc(h.GetPermissions)))).Methods("GET")
+	r.Handle("/admin/roles", m.JWTAuth(middleware.RequirePermission(rbac.RolesManageAny)(http.HandlerFunc(h.GetRoles)))).Methods("GET")
+	r.Handle("/admin/roles", m.JWTAuth(middleware.RequirePermission(rbac.RolesManageAny)(http.HandlerFunc(h.CreateRole)))).Methods("POST")
+	r.Handle("/admin/roles/{role_id}", m.JWTAuth(middleware.RequirePermission(rbac.RolesManageAny)(http.HandlerFunc(h.UpdateRole)))).Methods("PUT")
+	r.Handle("/admin/roles/{role_id}", m.JWTAuth(middleware.RequirePermission(rbac.RolesManageAny)(http.HandlerFunc(h.DeleteRole)))).Methods("DELETE")
+	r.Handle("/admin/users/{user_id}/role", m.JWTAuth(middleware.RequirePermission(rbac.UsersRoleAny)(http.HandlerFunc(h.SetUserRole)))).Methods("PUT")
+
+	r.Handle("/admin/commission-rules", m.JWTAuth(middleware.RequirePermission(rbac.CommissionAny)(http.HandlerFunc(h.GetCommissionRules)))).Methods("GET")
+	r.Handle("/admin/commission-rules", m.JWTAuth(middleware.RequirePermission(rbac.CommissionAny)(http.HandlerFunc(h.CreateCommissionRule)))).Methods("POST")
+	r.Handle("/admin/commission-rules/{rule_id}", m.JWTAuth(middleware.RequirePermission(rbac.CommissionAny)(http.HandlerFunc(h.DeleteCommissionRule)))).Methods("DELETE")
+	r.Handle("/admin/reviews", m.JWTAuth(middleware.RequirePermission(rbac.ReviewsModerateAny)(http.HandlerFunc(h.GetReviewsForModeration)))).Methods("GET")
+	r.Handle("/admin/reviews/{review_id}/moderate", m.JWTAuth(middleware.RequirePermission(rbac.ReviewsModerateAny)(http.HandlerFunc(h.ModerateReview)))).Methods("PUT")
+	r.Handle("/admin/payouts", m.JWTAuth(middleware.RequirePermission(rbac.PayoutsReadAny)(http.HandlerFunc(h.GetPayoutBatches)))).Methods("GET")
+	r.Handle("/admin/payouts/run", m.JWTAuth(middleware.RequirePermission(rbac.PayoutsRunAny)(http.HandlerFunc(h.RunPayouts)))).Methods("POST")
+
+	r.Handle("/admin/users", m.JWTAuth(middleware.RequirePermission(rbac.UsersReadAny)(http.HandlerFunc(h.AdminGetUsers)))).Methods("GET")
+	r.Handle("/admin/users/{user_id}/suspend", m.JWTAuth(middleware.RequirePermission(rbac.UsersSuspendAny)(http.HandlerFunc(h.AdminSuspendUser)))).Methods("POST")
+	r.Handle("/admin/users/{user_id}/reactivate", m.JWTAuth(middleware.RequirePermission(rbac.UsersSuspendAny)(http.HandlerFunc(h.AdminReactivateUser)))).Methods("POST")
+	r.Handle("/admin/shops", m.JWTAuth(middleware.RequirePermission(rbac.ShopsReadAny)(http.HandlerFunc(h.AdminGetShops)))).Methods("GET")
+	r.Handle("/admin/shops/{shop_id}/status", m.JWTAuth(middleware.RequirePermission(rbac.ShopsModerateAny)(http.HandlerFunc(h.AdminSetShopStatus)))).Methods("PUT")
+	r.Handle("/admin/shops/{shop_id}/approve", m.JWTAuth(middleware.RequirePermission(rbac.ShopsApproveAny)(http.HandlerFunc(h.AdminApproveShop)))).Methods("POST")
+	r.Handle("/admin/shops/{shop_id}/reject", m.JWTAuth(middleware.RequirePermission(rbac.ShopsApproveAny)(http.HandlerFunc(h.AdminRejectShop)))).Methods("POST")
+	r.Handle("/admin/products", m.JWTAuth(middleware.RequirePermission(rbac.ProductsReadAny)(http.HandlerFunc(h.AdminGetProducts)))).Methods("GET")
+	r.Handle("/admin/products/{product_id}/unpublish", m.JWTAuth(middleware.RequirePermission(rbac.ProductsUnpublish)(http.HandlerFunc(h.AdminUnpublishProduct)))).Methods("POST")
+	r.Handle("/admin/products/{product_id}/publish", m.JWTAuth(middleware.RequirePermission(rbac.ProductsUnpublish)(http.HandlerFunc(h.AdminPublishProduct)))).Methods("POST")
+	r.Handle("/admin/orders", m.JWTAuth(middleware.RequirePermission(rbac.OrdersReadAny)(http.HandlerFunc(h.AdminGetOrders)))).Methods("GET")
+	r.Handle("/admin/orders/{order_id}/status", m.JWTAuth(middleware.RequirePermission(rbac.OrdersUpdateAny)(http.HandlerFunc(h.AdminSetOrderStatus)))).Methods("PUT")
+	r.Handle("/admin/audit-logs", m.JWTAuth(middleware.RequirePermission(rbac.AuditReadAny)(http.HandlerFunc(h.GetAuditLogs)))).Methods("GET")
+
+	// Seller endpoints can select the active shop in the path instead of the X-Shop-ID header,
+	// e.g. /shops/5/shop/orders or /shops/5/product/my-products.
+	r.PathPrefix("/shops/{shop_id:[0-9]+}/").Handler(shopScoped(r))
 
 	r.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/docs/swagger.json"), // The url pointing to API definition
@@ -42,3 +157,16 @@ func InitRoutes() *mux.Router {
 
 	return r
 }
+
+// shopScoped serves /shops/{shop_id}/<path> as /<path> with the shop selected through the X-Shop-ID header.
+func shopScoped(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shopID := mux.Vars(r)["shop_id"]
+
+		scoped := r.Clone(r.Context())
+		scoped.URL.Path = strings.TrimPrefix(r.URL.Path, "/shops/"+shopID)
+		scoped.URL.RawPath = ""
+		scoped.Header.Set(controller.ShopHeader, shopID)
+		router.ServeHTTP(w, scoped)
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"e_commerce/config"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Server is an HTTP server with timeouts that shuts down gracefully on SIGINT or SIGTERM.
+type Server struct {
+	http       *http.Server
+	cfg        config.Server
+	certs      *certReloader
+	onShutdown []func() error
+}
+
+// New creates the server. HTTPS is served when a certificate and key are configured; the certificate is
+// loaded now so a broken pair is reported at startup.
+func New(cfg config.Server, handler http.Handler) (*Server, error) {
+	s := &Server{
+		cfg: cfg,
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+	}
+
+	if cfg.TLSCertFile != "" {
+		certs, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = certs
+		s.http.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+	return s, nil
+}
+
+// OnShutdown registers a function that runs after the in-flight requests are drained, such as closing the database pool.
+func (s *Server) OnShutdown(fn func() error) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Run serves requests until the process receives SIGINT or SIGTERM, then stops accepting connections,
+// waits up to the shutdown timeout for the running requests and runs the shutdown functions.
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if s.certs != nil {
+		go s.certs.watch(ctx, s.cfg.TLSReloadInterval)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if s.certs != nil {
+			log.Println("Server started with TLS on", s.cfg.Addr)
+			errc <- s.http.ListenAndServeTLS("", "")
+			return
+		}
+		log.Println("Server started on", s.cfg.Addr)
+		errc <- s.http.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // İkinci sinyal süreci beklemeden sonlandırır
+
+	log.Println("Shutting down, waiting for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	for _, fn := range s.onShutdown {
+		if err := fn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
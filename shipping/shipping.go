@@ -0,0 +1,50 @@
+package shipping
+
+import (
+	"e_commerce/models"
+	"errors"
+)
+
+var ErrNoRate = errors.New("no shipping rate matches the order")
+
+// ShipmentStatuses are the statuses a seller can report on a shipment, in their usual order.
+var ShipmentStatuses = []string{"label_created", "in_transit", "out_for_delivery", "delivered", "exception"}
+
+// CalculateRate returns the cheapest rate of the method that covers the given weight and order amount.
+func CalculateRate(method models.ShippingMethod, weight, amount float64) (float64, error) {
+	found := false
+	var cost float64
+	for _, rate := range method.Rates {
+		if !rateMatches(rate, weight, amount) {
+			continue
+		}
+		if !found || rate.Cost < cost {
+			cost = rate.Cost
+			found = true
+		}
+	}
+
+	if !found {
+		return 0, ErrNoRate
+	}
+	return cost, nil
+}
+
+func rateMatches(rate models.ShippingRate, weight, amount float64) bool {
+	if weight < rate.MinWeight || (rate.MaxWeight > 0 && weight > rate.MaxWeight) {
+		return false
+	}
+	if amount < rate.MinOrderAmount || (rate.MaxOrderAmount > 0 && amount > rate.MaxOrderAmount) {
+		return false
+	}
+	return true
+}
+
+func ValidStatus(status string) bool {
+	for _, s := range ShipmentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package totp
+
+import (
+	"e_commerce/config"
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists eight digit codes; a six digit code is the same value modulo 10^6.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAcceptsClockSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	tests := []struct {
+		offset int64
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, current+tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code, now)
+		if ok != tt.ok {
+			t.Errorf("code of step %+d: ok = %v, want %v", tt.offset, ok, tt.ok)
+		}
+		if ok && step != current+tt.offset {
+			t.Errorf("code of step %+d matched step %d", tt.offset, step)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate(%q) accepted", code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, "287 082", now); !ok {
+		t.Error("Validate rejected a code with a space")
+	}
+}
+
+func TestAcceptRejectsReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+	code, _ := Code(rfcSecret, current)
+
+	step, ok := Accept(rfcSecret, code, now, 0)
+	if !ok || step != current {
+		t.Fatalf("first use: step %d, ok %v", step, ok)
+	}
+	if _, ok := Accept(rfcSecret, code, now, step); ok {
+		t.Error("the same code was accepted twice")
+	}
+
+	// An older code that is still inside the skew window is a replay once a newer one was used.
+	previous, _ := Code(rfcSecret, current-1)
+	if _, ok := Accept(rfcSecret, previous, now, step); ok {
+		t.Error("a code older than the last accepted one was accepted")
+	}
+
+	next, _ := Code(rfcSecret, current+1)
+	if got, ok := Accept(rfcSecret, next, now.Add(Period*time.Second), step); !ok || got != current+1 {
+		t.Errorf("next code: step %d, ok %v", got, ok)
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	c, err := NewCipher(config.Auth{TOTPEncryptionKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := c.Seal(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sealed(sealed) || sealed == rfcSecret {
+		t.Fatalf("Seal returned %q", sealed)
+	}
+	if again, _ := c.Seal(rfcSecret); again == sealed {
+		t.Error("Seal reused its nonce")
+	}
+
+	opened, err := c.Open(sealed)
+	if err != nil || opened != rfcSecret {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+
+	if opened, err := c.Open(rfcSecret); err != nil || opened != rfcSecret {
+		t.Errorf("Open of a plain secret = %q, %v", opened, err)
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	if _, err := c.Open(tampered); err != ErrSealedSecret {
+		t.Errorf("Open of a tampered secret: %v", err)
+	}
+
+	other, err := NewCipher(config.Auth{TOTPEncryptionKey: "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed); err != ErrSealedSecret {
+		t.Errorf("Open with another key: %v", err)
+	}
+}